@@ -31,10 +31,23 @@ const (
 	CertificatesCorruptedReason           = "CertificatesCorrupted"
 )
 
+// Format specifies the output format of the bootstrap data
+// +kubebuilder:validation:Enum=cloud-config;ignition
+type Format string
+
+const (
+	// CloudConfig makes the bootstrap data cloud-init user data
+	CloudConfig Format = "cloud-config"
+	// Ignition makes the bootstrap data an Ignition v3 config, e.g. for Flatcar and Fedora CoreOS
+	Ignition Format = "ignition"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 
-// Ok3sConfig is the Schema for the ok3sconfigs API
+// Ok3sConfig is the Schema for the ok3sconfigs API. The machines join the cluster with the
+// token of the <cluster>-token secret, which is created by the control plane provider or, when
+// it does not exist, by the config of the machine initializing the cluster.
 type Ok3sConfig struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -53,6 +66,10 @@ type Ok3sConfigSpec struct {
 	// +optional
 	Version string `json:"version,omitempty"`
 
+	// Format specifies the output format of the bootstrap data, defaults to cloud-config
+	// +optional
+	Format Format `json:"format,omitempty"`
+
 	// ServerConfig specifies configuration for the agent nodes
 	// +optional
 	ServerConfig KThreesServerConfig `json:"serverConfig,omitempty"`
@@ -17,7 +17,11 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	bootstrapv1 "github.com/oneblock-ai/okr/api/bootstrap/v1"
 )
 
 const (
@@ -25,26 +29,111 @@ const (
 	Ok3sControlPlaneFinalizer = "ok3s.controlplane.cluster.x-k8s.io"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+const (
+	// AvailableCondition documents that the first control plane machine has bootstrapped the
+	// cluster and the API server is reachable through the control plane endpoint.
+	AvailableCondition clusterv1.ConditionType = "Available"
+
+	// WaitingForBootstrapReason documents a control plane whose first machine has not finished
+	// bootstrapping the cluster yet.
+	WaitingForBootstrapReason = "WaitingForBootstrap"
+
+	// MachinesReadyCondition reports an aggregate of the Ready conditions of the control plane machines.
+	MachinesReadyCondition clusterv1.ConditionType = "MachinesReady"
+
+	// ResizedCondition documents that the control plane has the desired number of replicas.
+	ResizedCondition clusterv1.ConditionType = "Resized"
+
+	// ScalingUpReason documents a control plane that is creating machines.
+	ScalingUpReason = "ScalingUp"
+
+	// ScalingDownReason documents a control plane that is deleting machines.
+	ScalingDownReason = "ScalingDown"
+)
 
 // Ok3sControlPlaneSpec defines the desired state of Ok3sControlPlane
 type Ok3sControlPlaneSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Replicas is the number of desired control plane machines, use an odd number to keep
+	// etcd quorum when a machine fails. Defaults to 1.
+	// +optional
+	// +kubebuilder:default=1
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Version is the Kubernetes version of the control plane machines, e.g. v1.28.4+k3s2.
+	Version string `json:"version"`
+
+	// MachineTemplate describes the machines created for the control plane.
+	MachineTemplate Ok3sControlPlaneMachineTemplate `json:"machineTemplate"`
+
+	// Ok3sConfigSpec is the bootstrap config of the control plane machines, its version is
+	// set from Version.
+	// +optional
+	Ok3sConfigSpec bootstrapv1.Ok3sConfigSpec `json:"ok3sConfigSpec,omitempty"`
+}
 
-	// Foo is an example field of Ok3sControlPlane. Edit ok3scontrolplane_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+// Ok3sControlPlaneMachineTemplate defines the template for the machines of the control plane.
+type Ok3sControlPlaneMachineTemplate struct {
+	// ObjectMeta holds the labels and annotations of the machines.
+	// +optional
+	ObjectMeta clusterv1.ObjectMeta `json:"metadata,omitempty"`
+
+	// InfrastructureRef is a reference to the infrastructure machine template the
+	// infrastructure of each control plane machine is cloned from.
+	InfrastructureRef corev1.ObjectReference `json:"infrastructureRef"`
 }
 
 // Ok3sControlPlaneStatus defines the observed state of Ok3sControlPlane
 type Ok3sControlPlaneStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Selector is the label selector of the control plane machines in string form, it is
+	// used by the scale subresource.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+
+	// Replicas is the number of non-terminated control plane machines.
+	// +optional
+	Replicas int32 `json:"replicas"`
+
+	// UpdatedReplicas is the number of non-terminated control plane machines with the desired version.
+	// +optional
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+
+	// ReadyReplicas is the number of control plane machines with a ready node.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// Version is the lowest Kubernetes version of the control plane machines.
+	// +optional
+	Version *string `json:"version,omitempty"`
+
+	// Initialized is true once the first control plane machine has bootstrapped the cluster.
+	// +optional
+	Initialized bool `json:"initialized"`
+
+	// Ready is true when the API server is ready to receive requests.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// ObservedGeneration is the latest generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions defines current service state of the Ok3sControlPlane.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+//+kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels['cluster\\.x-k8s\\.io/cluster-name']",description="Cluster"
+//+kubebuilder:printcolumn:name="Initialized",type=boolean,JSONPath=".status.initialized",description="The cluster has been bootstrapped by the first control plane machine"
+//+kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=".status.ready",description="The API server is ready to receive requests"
+//+kubebuilder:printcolumn:name="Desired",type=integer,JSONPath=".spec.replicas",description="Desired number of control plane machines"
+//+kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=".status.replicas",description="Number of control plane machines"
+//+kubebuilder:printcolumn:name="Ready Replicas",type=integer,JSONPath=".status.readyReplicas",description="Number of control plane machines with a ready node"
+//+kubebuilder:printcolumn:name="Updated",type=integer,JSONPath=".status.updatedReplicas",description="Number of control plane machines with the desired version"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of Ok3sControlPlane"
+//+kubebuilder:printcolumn:name="Version",type=string,JSONPath=".spec.version",description="Kubernetes version of the control plane"
 
 // Ok3sControlPlane is the Schema for the ok3scontrolplanes API
 type Ok3sControlPlane struct {
@@ -64,6 +153,14 @@ type Ok3sControlPlaneList struct {
 	Items           []Ok3sControlPlane `json:"items"`
 }
 
+func (c *Ok3sControlPlane) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+func (c *Ok3sControlPlane) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
 func init() {
 	SchemeBuilder.Register(&Ok3sControlPlane{}, &Ok3sControlPlaneList{})
 }
@@ -22,6 +22,7 @@ package v1
 
 import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -29,8 +30,8 @@ func (in *Ok3sControlPlane) DeepCopyInto(out *Ok3sControlPlane) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Ok3sControlPlane.
@@ -83,9 +84,33 @@ func (in *Ok3sControlPlaneList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Ok3sControlPlaneMachineTemplate) DeepCopyInto(out *Ok3sControlPlaneMachineTemplate) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.InfrastructureRef = in.InfrastructureRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Ok3sControlPlaneMachineTemplate.
+func (in *Ok3sControlPlaneMachineTemplate) DeepCopy() *Ok3sControlPlaneMachineTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(Ok3sControlPlaneMachineTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Ok3sControlPlaneSpec) DeepCopyInto(out *Ok3sControlPlaneSpec) {
 	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	in.MachineTemplate.DeepCopyInto(&out.MachineTemplate)
+	in.Ok3sConfigSpec.DeepCopyInto(&out.Ok3sConfigSpec)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Ok3sControlPlaneSpec.
@@ -101,6 +126,18 @@ func (in *Ok3sControlPlaneSpec) DeepCopy() *Ok3sControlPlaneSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Ok3sControlPlaneStatus) DeepCopyInto(out *Ok3sControlPlaneStatus) {
 	*out = *in
+	if in.Version != nil {
+		in, out := &in.Version, &out.Version
+		*out = new(string)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Ok3sControlPlaneStatus.
@@ -0,0 +1,48 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/oneblock-ai/okr/pkg/okr"
+)
+
+func NewAgent() *cobra.Command {
+	a := Agent{}
+	cmd := &cobra.Command{
+		Use:   "agent [flags]",
+		Short: "Run the periodic instructions of a bootstrapped node and re-apply its plan when the config changes",
+		RunE:  a.Run,
+	}
+	a.init(cmd)
+	return cmd
+}
+
+type Agent struct {
+	Interval string
+	Watch    bool
+}
+
+func (a *Agent) Run(cmd *cobra.Command, args []string) error {
+	interval, err := time.ParseDuration(a.Interval)
+	if err != nil {
+		return fmt.Errorf("failed to parse duration %s: %w", a.Interval, err)
+	}
+
+	r := okr.New(okr.Config{
+		DataDir:    okr.DefaultDataDir,
+		ConfigPath: okr.DefaultConfigFile,
+	})
+	return r.Agent(cmd.Context(), okr.AgentConfig{
+		Interval: interval,
+		Watch:    a.Watch,
+	})
+}
+
+func (a *Agent) init(apiCmd *cobra.Command) {
+	f := apiCmd.Flags()
+	f.StringVar(&a.Interval, "interval", "30s", "Interval to check the periodic instructions for an elapsed period")
+	f.BoolVar(&a.Watch, "watch", true, "Watch the config files and re-apply the plan when they change it")
+}
@@ -0,0 +1,71 @@
+package bundle
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/airgap"
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/versions"
+	"github.com/oneblock-ai/okr/pkg/okr"
+)
+
+func NewBundle() *cobra.Command {
+	b := Bundle{}
+	cmd := &cobra.Command{
+		Use:   "bundle [flags]",
+		Short: "Package the runtime and KubeRay images and charts for an air-gapped install",
+		Long: "Package the system agent installer image, the runtime airgap images, the KubeRay charts and " +
+			"operator images of the config into one archive. Set airgap.bundle to its path on the node to install from it.",
+		RunE: b.Run,
+	}
+	b.init(cmd)
+	cmd.AddCommand(NewExtract())
+	return cmd
+}
+
+type Bundle struct {
+	ConfigPath        string
+	KubernetesVersion string
+	KubeRayVersion    string
+	Arch              string
+	Images            []string
+	Output            string
+}
+
+func (b *Bundle) Run(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load(b.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if b.KubernetesVersion != "" {
+		cfg.KubernetesVersion = b.KubernetesVersion
+	}
+	if b.KubeRayVersion != "" {
+		cfg.KubeRay.Version = b.KubeRayVersion
+	}
+
+	opts, err := cfg.Channels.VersionOptions(okr.DefaultDataDir)
+	if err != nil {
+		return err
+	}
+	versions.Configure(opts)
+
+	return airgap.Create(cmd.Context(), &cfg, airgap.BundleConfig{
+		Arch:   b.Arch,
+		Images: b.Images,
+		Output: b.Output,
+	})
+}
+
+func (b *Bundle) init(apiCmd *cobra.Command) {
+	f := apiCmd.Flags()
+	f.StringVar(&b.ConfigPath, "config", okr.DefaultConfigFile, "Config file the bundle is created for")
+	f.StringVar(&b.KubernetesVersion, "kubernetes-version", "", "Kubernetes version or channel to bundle instead of the one in the config")
+	f.StringVar(&b.KubeRayVersion, "kuberay-version", "", "KubeRay version to bundle instead of the one in the config")
+	f.StringVar(&b.Arch, "arch", runtime.GOARCH, "Architecture of the nodes, e.g. amd64 or arm64")
+	f.StringSliceVar(&b.Images, "image", nil, "Additional image to bundle, can be repeated")
+	f.StringVarP(&b.Output, "output", "o", "okr-bundle.tar", "Bundle file to create")
+}
@@ -0,0 +1,24 @@
+package bundle
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/airgap"
+)
+
+func NewExtract() *cobra.Command {
+	e := Extract{}
+	return &cobra.Command{
+		Use:   "extract BUNDLE",
+		Short: "Extract a bundle into the system agent and runtime images and charts dirs",
+		Args:  cobra.ExactArgs(1),
+		RunE:  e.Run,
+	}
+}
+
+type Extract struct {
+}
+
+func (e *Extract) Run(cmd *cobra.Command, args []string) error {
+	return airgap.Extract(args[0])
+}
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewConfig() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the okr config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(
+		NewShow(),
+		NewValidate(),
+	)
+	return cmd
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	config2 "github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/okr"
+)
+
+func NewShow() *cobra.Command {
+	s := Show{}
+	cmd := &cobra.Command{
+		Use:   "show [flags]",
+		Short: "Print the effective merged config",
+		RunE:  s.Run,
+	}
+	s.init(cmd)
+	return cmd
+}
+
+type Show struct {
+	ConfigPath  string
+	Origin      bool
+	ShowSecrets bool
+}
+
+func (s *Show) Run(cmd *cobra.Command, args []string) error {
+	values, origins, err := config2.LoadWithOrigins(s.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	if !s.ShowSecrets {
+		values = config2.Redact(values)
+	}
+
+	var data []byte
+	if s.Origin {
+		data, err = config2.MarshalWithOrigins(values, origins)
+	} else {
+		data, err = yaml.Marshal(values)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(cmd.OutOrStdout(), string(data))
+	return err
+}
+
+func (s *Show) init(apiCmd *cobra.Command) {
+	f := apiCmd.Flags()
+	f.StringVar(&s.ConfigPath, "config", okr.DefaultConfigFile, "Config file")
+	f.BoolVar(&s.Origin, "origin", false, "Annotate every key with the file(s) it came from")
+	f.BoolVar(&s.ShowSecrets, "show-secrets", false, "Do not redact secrets such as the token")
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func show(t *testing.T, args ...string) string {
+	t.Helper()
+	out := &bytes.Buffer{}
+	cmd := NewShow()
+	cmd.SetOut(out)
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestShowRedactsSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.MkdirAll(path+".d", 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("token: secret-token\nnodeName: node1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	registries := `registries:
+  configs:
+    registry.example.com:
+      auth:
+        username: user
+        password: secret-password
+`
+	if err := os.WriteFile(filepath.Join(path+".d", "10-registries.yaml"), []byte(registries), 0600); err != nil {
+		t.Fatal(err)
+	}
+	secrets := []string{"secret-token", "secret-password"}
+
+	for _, args := range [][]string{
+		{"--config", path},
+		{"--config", path, "--origin"},
+	} {
+		out := show(t, args...)
+		for _, secret := range secrets {
+			if strings.Contains(out, secret) {
+				t.Errorf("expected %s to be redacted with %v, got\n%s", secret, args, out)
+			}
+		}
+		if !strings.Contains(out, "node1") || !strings.Contains(out, "user") {
+			t.Errorf("expected the other values with %v, got\n%s", args, out)
+		}
+	}
+
+	out := show(t, "--config", path, "--show-secrets")
+	for _, secret := range secrets {
+		if !strings.Contains(out, secret) {
+			t.Errorf("expected %s with --show-secrets, got\n%s", secret, out)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	config2 "github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/okr"
+)
+
+func NewValidate() *cobra.Command {
+	v := Validate{}
+	cmd := &cobra.Command{
+		Use:          "validate [flags]",
+		Short:        "Validate the merged config and report unknown keys and invalid values",
+		SilenceUsage: true,
+		RunE:         v.Run,
+	}
+	v.init(cmd)
+	return cmd
+}
+
+type Validate struct {
+	ConfigPath string
+}
+
+func (v *Validate) Run(cmd *cobra.Command, args []string) error {
+	issues, err := config2.Validate(v.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	for _, issue := range issues {
+		fmt.Fprintln(cmd.OutOrStdout(), issue.String())
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("config is invalid, found %d issue(s)", len(issues))
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), "config is valid")
+	return nil
+}
+
+func (v *Validate) init(apiCmd *cobra.Command) {
+	f := apiCmd.Flags()
+	f.StringVar(&v.ConfigPath, "config", okr.DefaultConfigFile, "Config file")
+}
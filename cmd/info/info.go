@@ -1,21 +1,32 @@
 package info
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
 
 	"github.com/oneblock-ai/okr/pkg/okr"
 )
 
 func NewInfo() *cobra.Command {
 	b := Info{}
-	return &cobra.Command{
-		Use:   "info",
-		Short: "Print installation versions",
+	cmd := &cobra.Command{
+		Use:   "info [flags]",
+		Short: "Print the status of the node, its plan and the cluster",
 		RunE:  b.Run,
 	}
+	b.init(cmd)
+	return cmd
 }
 
 type Info struct {
+	Output string
 }
 
 func (b *Info) Run(cmd *cobra.Command, args []string) error {
@@ -23,5 +34,101 @@ func (b *Info) Run(cmd *cobra.Command, args []string) error {
 		DataDir:    okr.DefaultDataDir,
 		ConfigPath: okr.DefaultConfigFile,
 	})
-	return o.Info(cmd.Context())
+	status := o.Status(cmd.Context())
+
+	var (
+		data []byte
+		err  error
+	)
+	switch b.Output {
+	case "json":
+		data, err = json.MarshalIndent(status, "", "  ")
+	case "yaml":
+		data, err = yaml.Marshal(status)
+	case "table":
+		return printTable(cmd.OutOrStdout(), status)
+	default:
+		return fmt.Errorf("unsupported output format %s, must be json, yaml or table", b.Output)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), string(data))
+	return err
+}
+
+func (b *Info) init(apiCmd *cobra.Command) {
+	f := apiCmd.Flags()
+	f.StringVarP(&b.Output, "output", "o", "table", "Output format, json, yaml or table")
+}
+
+func printTable(out io.Writer, status *okr.Status) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "OKR version:\t%s\n", status.OKRVersion)
+
+	fmt.Fprintln(w, "\nNODE")
+	bootstrappedAt := ""
+	if status.Node.BootstrappedAt != nil {
+		bootstrappedAt = status.Node.BootstrappedAt.Format(time.RFC3339)
+	}
+	fmt.Fprintf(w, "Name:\t%s\n", status.Node.Name)
+	fmt.Fprintf(w, "Role:\t%s\n", status.Node.Role)
+	fmt.Fprintf(w, "Runtime:\t%s\n", status.Node.Runtime)
+	fmt.Fprintf(w, "Kubernetes version:\t%s\n", status.Node.KubernetesVersion)
+	fmt.Fprintf(w, "Bootstrapped:\t%t\n", status.Node.Bootstrapped)
+	fmt.Fprintf(w, "Bootstrapped at:\t%s\n", bootstrappedAt)
+	printError(w, status.Node.Error)
+
+	fmt.Fprintln(w, "\nPLAN")
+	fmt.Fprintf(w, "File:\t%s\n", status.Plan.File)
+	fmt.Fprintf(w, "Checksum:\t%s\n", status.Plan.Checksum)
+	printError(w, status.Plan.Error)
+
+	fmt.Fprintln(w, "\nPROBES")
+	for _, result := range status.Probes.Results {
+		fmt.Fprintf(w, "%s:\t%s\n", result.Name, health(result.Healthy, result.Error))
+	}
+	printError(w, status.Probes.Error)
+
+	fmt.Fprintln(w, "\nKUBERAY")
+	fmt.Fprintf(w, "Version:\t%s\n", status.KubeRay.Version)
+	fmt.Fprintf(w, "Image:\t%s\n", status.KubeRay.Image)
+	for _, chart := range status.KubeRay.HelmCharts {
+		chartStatus := chart.Status
+		if chart.Error != "" {
+			chartStatus = "error: " + chart.Error
+		}
+		fmt.Fprintf(w, "HelmChart %s:\t%s\t%s\n", chart.Name, chart.Version, chartStatus)
+	}
+	printError(w, status.KubeRay.Error)
+
+	fmt.Fprintln(w, "\nCLUSTER")
+	fmt.Fprintf(w, "Kubernetes version:\t%s\n", status.Kubernetes.Version)
+	printError(w, status.Kubernetes.Error)
+	if len(status.Kubernetes.Nodes) > 0 {
+		fmt.Fprintln(w, "\nNAME\tROLES\tREADY\tVERSION")
+		for _, node := range status.Kubernetes.Nodes {
+			fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", node.Name, strings.Join(node.Roles, ","), node.Ready, node.Version)
+		}
+	}
+
+	return w.Flush()
+}
+
+func printError(w io.Writer, err string) {
+	if err != "" {
+		fmt.Fprintf(w, "Error:\t%s\n", err)
+	}
+}
+
+func health(healthy bool, err string) string {
+	if healthy {
+		return "healthy"
+	}
+	if err != "" {
+		return "unhealthy: " + err
+	}
+	return "unhealthy"
 }
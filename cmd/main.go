@@ -7,10 +7,16 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
+	"github.com/oneblock-ai/okr/cmd/agent"
 	"github.com/oneblock-ai/okr/cmd/bootstrap"
+	"github.com/oneblock-ai/okr/cmd/bundle"
+	"github.com/oneblock-ai/okr/cmd/config"
 	"github.com/oneblock-ai/okr/cmd/info"
+	"github.com/oneblock-ai/okr/cmd/plan"
 	"github.com/oneblock-ai/okr/cmd/probe"
+	"github.com/oneblock-ai/okr/cmd/reset"
 	"github.com/oneblock-ai/okr/cmd/retry"
+	"github.com/oneblock-ai/okr/cmd/upgrade"
 )
 
 type OKR struct {
@@ -36,10 +42,16 @@ func New() *cobra.Command {
 	}
 
 	rootCmd.AddCommand(
+		agent.NewAgent(),
 		bootstrap.NewBootstrap(),
+		bundle.NewBundle(),
+		config.NewConfig(),
 		info.NewInfo(),
+		plan.NewPlan(),
 		probe.NewProbe(),
+		reset.NewReset(),
 		retry.NewRetry(),
+		upgrade.NewUpgrade(),
 	)
 
 	rootCmd.InitDefaultHelpCmd()
@@ -0,0 +1,105 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	plan2 "github.com/oneblock-ai/okr/pkg/k3s/plan"
+	"github.com/oneblock-ai/okr/pkg/okr"
+)
+
+func NewDiff() *cobra.Command {
+	d := Diff{}
+	cmd := &cobra.Command{
+		Use:   "diff [flags] [a] [b]",
+		Short: "Show the changes between two revisions of the plan history",
+		Long: "Show the changed files, instructions and probes from revision a to revision b. " +
+			"b defaults to the latest revision and a to the one before b.",
+		Args: cobra.MaximumNArgs(2),
+		RunE: d.Run,
+	}
+	d.init(cmd)
+	return cmd
+}
+
+type Diff struct {
+	DataDir string
+	Output  string
+}
+
+func (d *Diff) Run(cmd *cobra.Command, args []string) error {
+	numbers := []int{-1, 0}
+	for i, arg := range args {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid revision %s, must be a number from okr plan history", arg)
+		}
+		numbers[i] = n
+	}
+
+	a, err := plan2.GetRevision(d.DataDir, numbers[0])
+	if err != nil {
+		return err
+	}
+	b, err := plan2.GetRevision(d.DataDir, numbers[1])
+	if err != nil {
+		return err
+	}
+	planA, err := plan2.LoadRevision(a)
+	if err != nil {
+		return err
+	}
+	planB, err := plan2.LoadRevision(b)
+	if err != nil {
+		return err
+	}
+
+	changes, err := plan2.Diff(planA, planB)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	switch d.Output {
+	case "json":
+		data, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	case "text":
+	default:
+		return fmt.Errorf("unsupported output format %s, must be text or json", d.Output)
+	}
+
+	fmt.Fprintf(out, "Revision %d (%s) to %d (%s)\n", a.Number, a.Checksum, b.Number, b.Checksum)
+	if len(changes) == 0 {
+		fmt.Fprintln(out, "No changes")
+	}
+	for _, change := range changes {
+		fmt.Fprintf(out, "%s %s %s\n", operationSymbols[change.Operation], change.Kind, change.Name)
+		for _, line := range strings.Split(strings.TrimRight(change.Diff, "\n"), "\n") {
+			if line != "" {
+				fmt.Fprintf(out, "    %s\n", line)
+			}
+		}
+	}
+	return nil
+}
+
+var operationSymbols = map[string]string{
+	plan2.Added:   "+",
+	plan2.Removed: "-",
+	plan2.Changed: "~",
+}
+
+func (d *Diff) init(apiCmd *cobra.Command) {
+	f := apiCmd.Flags()
+	f.StringVar(&d.DataDir, "data-dir", okr.DefaultDataDir, "Data dir of the plan history")
+	f.StringVarP(&d.Output, "output", "o", "text", "Output format, text or json")
+}
@@ -0,0 +1,47 @@
+package plan
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	plan2 "github.com/oneblock-ai/okr/pkg/k3s/plan"
+	"github.com/oneblock-ai/okr/pkg/okr"
+)
+
+func NewHistory() *cobra.Command {
+	h := History{}
+	cmd := &cobra.Command{
+		Use:   "history [flags]",
+		Short: "List the plans applied to the node",
+		Args:  cobra.NoArgs,
+		RunE:  h.Run,
+	}
+	h.init(cmd)
+	return cmd
+}
+
+type History struct {
+	DataDir string
+}
+
+func (h *History) Run(cmd *cobra.Command, args []string) error {
+	history, err := plan2.History(h.DataDir)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tCHECKSUM\tAPPLIED")
+	for _, revision := range history {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", revision.Number, revision.Checksum[:min(12, len(revision.Checksum))], revision.Time.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func (h *History) init(apiCmd *cobra.Command) {
+	f := apiCmd.Flags()
+	f.StringVar(&h.DataDir, "data-dir", okr.DefaultDataDir, "Data dir of the plan history")
+}
@@ -0,0 +1,22 @@
+package plan
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewPlan() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Inspect the node plan",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	}
+	cmd.AddCommand(
+		NewDiff(),
+		NewHistory(),
+		NewRender(),
+		NewRollback(),
+	)
+	return cmd
+}
@@ -0,0 +1,98 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	plan2 "github.com/oneblock-ai/okr/pkg/k3s/plan"
+	"github.com/oneblock-ai/okr/pkg/k3s/versions"
+	"github.com/oneblock-ai/okr/pkg/okr"
+)
+
+func NewRender() *cobra.Command {
+	r := Render{}
+	cmd := &cobra.Command{
+		Use:   "render [flags]",
+		Short: "Print the plan generated from the config without applying it",
+		RunE:  r.Run,
+	}
+	r.init(cmd)
+	return cmd
+}
+
+type Render struct {
+	ConfigPath  string
+	DataDir     string
+	Output      string
+	StagingDir  string
+	ShowSecrets bool
+}
+
+// generatedToken replaces the token bootstrap generates or reads from the node when none is
+// configured, so that the rendered plan is the same on every run
+const generatedToken = "<generated>"
+
+func (r *Render) Run(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load(r.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	opts, err := cfg.Channels.VersionOptions(r.DataDir)
+	if err != nil {
+		return err
+	}
+	versions.Configure(opts)
+
+	switch {
+	case cfg.Token == "":
+		cfg.Token = generatedToken
+	case !r.ShowSecrets:
+		cfg.Token = config.Redacted
+	}
+
+	nodePlan, err := plan2.ToPlan(cmd.Context(), &cfg, r.DataDir)
+	if err != nil {
+		return fmt.Errorf("generating plan: %w", err)
+	}
+
+	if r.StagingDir != "" {
+		if err := plan2.WriteFiles(nodePlan, r.StagingDir); err != nil {
+			return fmt.Errorf("writing plan files to %s: %w", r.StagingDir, err)
+		}
+	}
+
+	rendered, err := plan2.Render(nodePlan)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch r.Output {
+	case "json":
+		data, err = json.MarshalIndent(rendered, "", "  ")
+	case "yaml":
+		data, err = yaml.Marshal(rendered)
+	default:
+		return fmt.Errorf("unsupported output format %s, must be json or yaml", r.Output)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), string(data))
+	return err
+}
+
+func (r *Render) init(apiCmd *cobra.Command) {
+	f := apiCmd.Flags()
+	f.StringVar(&r.ConfigPath, "config", okr.DefaultConfigFile, "Config file")
+	f.StringVar(&r.DataDir, "data-dir", okr.DefaultDataDir, "Data dir the plan is generated for")
+	f.StringVarP(&r.Output, "output", "o", "yaml", "Output format, json or yaml")
+	f.StringVar(&r.StagingDir, "staging-dir", "", "Write the plan files below this directory")
+	f.BoolVar(&r.ShowSecrets, "show-secrets", false, "Do not redact secrets such as the token")
+}
@@ -0,0 +1,55 @@
+package plan
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, args ...string) string {
+	t.Helper()
+	out := &bytes.Buffer{}
+	cmd := NewRender()
+	cmd.SetOut(out)
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRenderIsDeterministicWithoutToken(t *testing.T) {
+	config := writeConfig(t, "role: cluster-init\nkubernetesVersion: v1.28.4+k3s2\nnodeName: node1\n")
+	args := []string{"--config", config, "--data-dir", t.TempDir()}
+
+	first, second := render(t, args...), render(t, args...)
+	if first != second {
+		t.Errorf("expected the same output on every run, got\n%s\nand\n%s", first, second)
+	}
+	if !strings.Contains(first, "K3S_TOKEN="+generatedToken) {
+		t.Errorf("expected the token placeholder in the output, got\n%s", first)
+	}
+}
+
+func TestRenderRedactsToken(t *testing.T) {
+	config := writeConfig(t, "role: cluster-init\nkubernetesVersion: v1.28.4+k3s2\nnodeName: node1\ntoken: secret-token\n")
+	args := []string{"--config", config, "--data-dir", t.TempDir()}
+
+	if out := render(t, args...); strings.Contains(out, "secret-token") {
+		t.Errorf("expected the token to be redacted, got\n%s", out)
+	}
+	if out := render(t, append(args, "--show-secrets")...); !strings.Contains(out, "K3S_TOKEN=secret-token") {
+		t.Errorf("expected the token with --show-secrets, got\n%s", out)
+	}
+}
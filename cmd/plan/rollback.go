@@ -0,0 +1,37 @@
+package plan
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/oneblock-ai/okr/pkg/okr"
+)
+
+func NewRollback() *cobra.Command {
+	r := Rollback{}
+	cmd := &cobra.Command{
+		Use:   "rollback <revision>",
+		Short: "Re-apply a plan from the plan history",
+		Args:  cobra.ExactArgs(1),
+		RunE:  r.Run,
+	}
+	return cmd
+}
+
+type Rollback struct {
+}
+
+func (r *Rollback) Run(cmd *cobra.Command, args []string) error {
+	number, err := strconv.Atoi(args[0])
+	if err != nil || number < 1 {
+		return fmt.Errorf("invalid revision %s, must be a number from okr plan history", args[0])
+	}
+
+	o := okr.New(okr.Config{
+		DataDir:    okr.DefaultDataDir,
+		ConfigPath: okr.DefaultConfigFile,
+	})
+	return o.Rollback(cmd.Context(), number)
+}
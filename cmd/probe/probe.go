@@ -1,6 +1,7 @@
 package probe
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -21,8 +22,12 @@ func NewProbe() *cobra.Command {
 }
 
 type Probe struct {
-	Interval string
-	File     string
+	Interval   string
+	Timeout    string
+	Once       bool
+	Output     string
+	File       string
+	ProbesFile string
 }
 
 func (p *Probe) Run(cmd *cobra.Command, args []string) error {
@@ -30,12 +35,37 @@ func (p *Probe) Run(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse duration %s: %w", p.Interval, err)
 	}
+	timeout, err := time.ParseDuration(p.Timeout)
+	if err != nil {
+		return fmt.Errorf("failed to parse duration %s: %w", p.Timeout, err)
+	}
+	if p.Output != "text" && p.Output != "json" {
+		return fmt.Errorf("unsupported output format %s, must be text or json", p.Output)
+	}
 
-	return probe.RunProbes(cmd.Context(), p.File, interval)
+	results, runErr := probe.RunProbes(cmd.Context(), p.File, p.ProbesFile, probe.RunConfig{
+		Interval: interval,
+		Timeout:  timeout,
+		Once:     p.Once,
+	})
+	if p.Output == "json" && results != nil {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(cmd.OutOrStdout(), string(data)); err != nil {
+			return err
+		}
+	}
+	return runErr
 }
 
 func (p *Probe) init(apiCmd *cobra.Command) {
 	f := apiCmd.Flags()
 	f.StringVar(&p.Interval, "interval", "5s", "Polling interval to run probes")
+	f.StringVar(&p.Timeout, "timeout", "0s", "Maximum time to wait for the probes to become healthy, 0 waits forever")
+	f.BoolVar(&p.Once, "once", false, "Run every probe a single time instead of waiting for them to become healthy")
+	f.StringVarP(&p.Output, "output", "o", "text", "Output format, text or json")
 	f.StringVar(&p.File, "file", "/var/lib/oneblock-ai/okr/plan/plan.json", "Plan file")
+	f.StringVar(&p.ProbesFile, "probes-file", "/var/lib/oneblock-ai/okr/probes.json", "File with the custom probes that are not HTTP probes")
 }
@@ -0,0 +1,51 @@
+package reset
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/oneblock-ai/okr/pkg/okr"
+)
+
+func NewReset() *cobra.Command {
+	r := Reset{}
+	cmd := &cobra.Command{
+		Use:   "reset [flags]",
+		Short: "Uninstall Kubernetes and reset the node to its pre-bootstrap state",
+		RunE:  r.Run,
+	}
+	r.init(cmd)
+	return cmd
+}
+
+type Reset struct {
+	DeleteNode   bool
+	KeepData     bool
+	DrainTimeout string
+}
+
+func (r *Reset) Run(cmd *cobra.Command, args []string) error {
+	drainTimeout, err := time.ParseDuration(r.DrainTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to parse duration %s: %w", r.DrainTimeout, err)
+	}
+
+	o := okr.New(okr.Config{
+		DataDir:    okr.DefaultDataDir,
+		ConfigPath: okr.DefaultConfigFile,
+	})
+	return o.Reset(cmd.Context(), okr.ResetConfig{
+		DeleteNode:   r.DeleteNode,
+		KeepData:     r.KeepData,
+		DrainTimeout: drainTimeout,
+	})
+}
+
+func (r *Reset) init(apiCmd *cobra.Command) {
+	f := apiCmd.Flags()
+	f.BoolVar(&r.DeleteNode, "delete-node", false, "Drain the node and remove it from the cluster before uninstalling")
+	f.BoolVar(&r.KeepData, "keep-data", false, "Preserve the etcd data of the runtime")
+	f.StringVar(&r.DrainTimeout, "drain-timeout", "5m", "Maximum time to wait for pods to be evicted, 0 waits forever")
+}
@@ -1,6 +1,7 @@
 package retry
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -11,22 +12,69 @@ import (
 func NewRetry() *cobra.Command {
 	r := &Retry{}
 	cmd := &cobra.Command{
-		Use:                "retry",
-		Short:              "Retry command until it succeeds",
-		Hidden:             true,
-		DisableFlagParsing: true,
-		RunE:               r.Run,
+		Use:    "retry [flags] -- command [args...]",
+		Short:  "Retry command until it succeeds",
+		Hidden: true,
+		Args:   cobra.MinimumNArgs(1),
+		RunE:   r.Run,
 	}
+	r.init(cmd)
 	return cmd
 }
 
 type Retry struct {
-	SleepFirst bool `usage:"Sleep 5 seconds before running command"`
+	SleepFirst      bool
+	InitialInterval string
+	MaxInterval     string
+	Multiplier      float64
+	Jitter          float64
+	MaxAttempts     int
+	AttemptTimeout  string
+	Timeout         string
 }
 
 func (p *Retry) Run(cmd *cobra.Command, args []string) error {
+	policy := retry.Policy{
+		Multiplier:  &p.Multiplier,
+		Jitter:      &p.Jitter,
+		MaxAttempts: p.MaxAttempts,
+	}
+	durations := []struct {
+		value string
+		to    *time.Duration
+	}{
+		{p.InitialInterval, &policy.InitialInterval},
+		{p.MaxInterval, &policy.MaxInterval},
+		{p.AttemptTimeout, &policy.AttemptTimeout},
+		{p.Timeout, &policy.Timeout},
+	}
+	for _, d := range durations {
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("failed to parse duration %s: %w", d.value, err)
+		}
+		*d.to = parsed
+	}
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid retry policy: %w", err)
+	}
+
 	if p.SleepFirst {
 		time.Sleep(5 * time.Second)
 	}
-	return retry.Retry(cmd.Context(), 15*time.Second, args)
+	return retry.Retry(cmd.Context(), policy, args)
+}
+
+func (p *Retry) init(apiCmd *cobra.Command) {
+	f := apiCmd.Flags()
+	// flags after the command belong to the command, e.g. okr retry kubectl apply -f x
+	f.SetInterspersed(false)
+	f.BoolVar(&p.SleepFirst, "sleep-first", false, "Sleep 5 seconds before running command")
+	f.StringVar(&p.InitialInterval, "initial-interval", retry.DefaultInitialInterval.String(), "Wait after the first failed attempt")
+	f.StringVar(&p.MaxInterval, "max-interval", retry.DefaultMaxInterval.String(), "Maximum wait between two attempts")
+	f.Float64Var(&p.Multiplier, "multiplier", retry.DefaultMultiplier, "Factor the wait grows by after every failed attempt")
+	f.Float64Var(&p.Jitter, "jitter", retry.DefaultJitter, "Fraction the wait is randomized by")
+	f.IntVar(&p.MaxAttempts, "max-attempts", 0, "Number of attempts before giving up, 0 retries forever")
+	f.StringVar(&p.AttemptTimeout, "attempt-timeout", "0s", "Maximum duration of a single attempt, 0 is unlimited")
+	f.StringVar(&p.Timeout, "timeout", "0s", "Maximum duration of all attempts, 0 is unlimited")
 }
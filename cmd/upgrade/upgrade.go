@@ -0,0 +1,43 @@
+package upgrade
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/oneblock-ai/okr/pkg/okr"
+)
+
+func NewUpgrade() *cobra.Command {
+	u := Upgrade{}
+	cmd := &cobra.Command{
+		Use:   "upgrade [flags]",
+		Short: "Upgrade the Kubernetes(k3s) and KubeRay versions of a bootstrapped node",
+		RunE:  u.Run,
+	}
+	u.init(cmd)
+	return cmd
+}
+
+type Upgrade struct {
+	KubernetesVersion string
+	KubeRayVersion    string
+	Force             bool
+}
+
+func (u *Upgrade) Run(cmd *cobra.Command, args []string) error {
+	r := okr.New(okr.Config{
+		DataDir:    okr.DefaultDataDir,
+		ConfigPath: okr.DefaultConfigFile,
+	})
+	return r.Upgrade(cmd.Context(), okr.UpgradeConfig{
+		KubernetesVersion: u.KubernetesVersion,
+		KubeRayVersion:    u.KubeRayVersion,
+		Force:             u.Force,
+	})
+}
+
+func (u *Upgrade) init(apiCmd *cobra.Command) {
+	f := apiCmd.Flags()
+	f.StringVar(&u.KubernetesVersion, "kubernetes-version", "", "Kubernetes version or channel to upgrade to, e.g. v1.29.0+k3s1")
+	f.StringVar(&u.KubeRayVersion, "kuberay-version", "", "KubeRay operator version to upgrade to, e.g. 1.1.0")
+	f.BoolVar(&u.Force, "force", false, "Run upgrade even if not bootstrapped or already at the requested versions")
+}
@@ -26,6 +26,11 @@ set -o noglob
 #   - INSTALL_OKR_SKIP_START
 #     If set to true will not start okr service.
 #
+#   - INSTALL_OKR_ENABLE_AGENT
+#     If set to true will also install, enable and start the okr-agent service,
+#     which runs the periodic instructions and re-applies the plan, restarting
+#     the runtime, whenever the okr config files change.
+#
 #   - INSTALL_OKR_VERSION
 #     Version of okr to download from github. Will attempt to download from the
 #     stable channel if not specified.
@@ -133,10 +138,13 @@ setup_env() {
 
     # --- set related files from system name ---
     SERVICE_OKR=${SYSTEM_NAME}.service
+    AGENT_NAME=${SYSTEM_NAME}-agent
+    SERVICE_OKR_AGENT=${AGENT_NAME}.service
 
     # --- use service or environment location depending on systemd ---
     FILE_OKR_SERVICE=${SYSTEMD_DIR}/${SERVICE_OKR}
     FILE_OKR_ENV=${SYSTEMD_DIR}/${SERVICE_OKR}.env
+    FILE_OKR_AGENT_SERVICE=${SYSTEMD_DIR}/${SERVICE_OKR_AGENT}
 
     # --- get hash of config & exec for currently installed okr ---
     PRE_INSTALL_HASHES=$(get_installed_hashes)
@@ -321,6 +329,11 @@ systemd_disable() {
     $SUDO systemctl disable ${SYSTEM_NAME} >/dev/null 2>&1 || true
     $SUDO rm -f /etc/systemd/system/${SERVICE_OKR} || true
     $SUDO rm -f /etc/systemd/system/${SERVICE_OKR}.env || true
+    $SUDO systemctl disable ${AGENT_NAME} >/dev/null 2>&1 || true
+    $SUDO rm -f /etc/systemd/system/${SERVICE_OKR_AGENT} || true
+    if [ "${INSTALL_OKR_ENABLE_AGENT}" != true ]; then
+        $SUDO systemctl stop ${AGENT_NAME} >/dev/null 2>&1 || true
+    fi
 }
 
 # --- capture current env and create file containing okr_ variables ---
@@ -362,15 +375,45 @@ ExecStart=${BIN_DIR}/okr bootstrap
 EOF
 }
 
+# --- write systemd service file of the agent running the periodic instructions ---
+create_systemd_agent_service_file() {
+    [ "${INSTALL_OKR_ENABLE_AGENT}" = true ] || return 0
+    info "systemd: Creating service file ${FILE_OKR_AGENT_SERVICE}"
+    $SUDO tee ${FILE_OKR_AGENT_SERVICE} >/dev/null << EOF
+[Unit]
+Description=OKR Agent
+Documentation=https://github.com/oneblock-ai/okr
+Wants=network-online.target
+After=network-online.target ${SERVICE_OKR}
+
+[Install]
+WantedBy=multi-user.target
+
+[Service]
+Type=simple
+EnvironmentFile=-/etc/default/%N
+EnvironmentFile=-/etc/sysconfig/%N
+EnvironmentFile=-${FILE_OKR_ENV}
+KillMode=process
+Restart=always
+RestartSec=5s
+ExecStart=${BIN_DIR}/okr agent
+EOF
+}
+
 # --- get hashes of the current okr bin and service files
 get_installed_hashes() {
-    $SUDO sha256sum ${BIN_DIR}/okr ${FILE_OKR_SERVICE} ${FILE_OKR_ENV} 2>&1 || true
+    $SUDO sha256sum ${BIN_DIR}/okr ${FILE_OKR_SERVICE} ${FILE_OKR_AGENT_SERVICE} ${FILE_OKR_ENV} 2>&1 || true
 }
 
 # --- enable and start systemd service ---
 systemd_enable() {
     info "systemd: Enabling ${SYSTEM_NAME} unit"
     $SUDO systemctl enable ${FILE_OKR_SERVICE} >/dev/null
+    if [ "${INSTALL_OKR_ENABLE_AGENT}" = true ]; then
+        info "systemd: Enabling ${AGENT_NAME} unit"
+        $SUDO systemctl enable ${FILE_OKR_AGENT_SERVICE} >/dev/null
+    fi
     $SUDO systemctl daemon-reload >/dev/null
 }
 
@@ -378,6 +421,10 @@ systemd_start() {
     info "systemd: Starting ${SYSTEM_NAME}"
     $SUDO systemctl restart --no-block ${SYSTEM_NAME}
     info "Run \"journalctl -u ${SYSTEM_NAME} -f\" to watch logs"
+    if [ "${INSTALL_OKR_ENABLE_AGENT}" = true ]; then
+        info "systemd: Starting ${AGENT_NAME}"
+        $SUDO systemctl restart --no-block ${AGENT_NAME}
+    fi
 }
 
 # --- enable and start openrc service ---
@@ -420,5 +467,6 @@ eval set -- $(escape "${INSTALL_OKR_EXEC}") $(quote "$@")
     systemd_disable
     create_env_file
     create_systemd_service_file
+    create_systemd_agent_service_file
     service_enable_and_start
 }
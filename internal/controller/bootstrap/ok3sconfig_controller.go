@@ -27,6 +27,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	bsutil "sigs.k8s.io/cluster-api/bootstrap/util"
@@ -35,15 +36,26 @@ import (
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/kubeconfig"
 	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
 	"sigs.k8s.io/cluster-api/util/secret"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	bootstrapv1 "github.com/oneblock-ai/okr/api/bootstrap/v1"
+	"github.com/oneblock-ai/okr/internal/locking"
+	"github.com/oneblock-ai/okr/pkg/cloudinit"
+	"github.com/oneblock-ai/okr/pkg/ignition"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/roles"
 	"github.com/oneblock-ai/okr/pkg/token"
 	olog "github.com/oneblock-ai/okr/pkg/utils/log"
 )
 
+// ErrFailedUnlock is returned when the init lock could not be released after a failed init.
+var ErrFailedUnlock = errors.New("failed to unlock the k3s init lock")
+
 // InitLocker is a lock that is used around k3s init.
 type InitLocker interface {
 	Lock(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine) bool
@@ -68,24 +80,84 @@ type Scope struct {
 
 // SetupWithManager sets up the bootstrap with the Manager.
 func (r *Ok3sConfigReconciler) SetupWithManager(mgr ctrl.Manager, ctx context.Context) error {
-	//if r.K3sInitLock == nil {
-	//	r.K3sInitLock = locking.NewControlPlaneInitMutex(ctrl.Log.WithName("init-locker"), mgr.GetClient())
-	//}
+	if r.K3sInitLock == nil {
+		r.K3sInitLock = locking.NewControlPlaneInitMutex(ctrl.Log.WithName("init-locker"), mgr.GetClient())
+	}
+
+	logger := log.FromContext(ctx)
 
-	return ctrl.NewControllerManagedBy(mgr).
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&bootstrapv1.Ok3sConfig{}).
-		Complete(r)
+		Watches(
+			&clusterv1.Machine{},
+			handler.EnqueueRequestsFromMapFunc(r.MachineToBootstrapMapFunc),
+		).
+		Build(r)
+	if err != nil {
+		return fmt.Errorf("failed setting up the bootstrap controller manager: %w", err)
+	}
+
+	// the configs wait for the infrastructure of their cluster to be ready
+	if err = c.Watch(
+		source.Kind(mgr.GetCache(), &clusterv1.Cluster{}),
+		handler.EnqueueRequestsFromMapFunc(r.ClusterToOk3sConfigs),
+		predicates.ClusterUnpausedAndInfrastructureReady(logger),
+	); err != nil {
+		return fmt.Errorf("failed adding a watch for ready clusters: %w", err)
+	}
+
+	return nil
+}
+
+// MachineToBootstrapMapFunc maps a Machine to the Ok3sConfig of its bootstrap config reference.
+func (r *Ok3sConfigReconciler) MachineToBootstrapMapFunc(_ context.Context, o client.Object) []ctrl.Request {
+	m, ok := o.(*clusterv1.Machine)
+	if !ok {
+		return nil
+	}
+	if ref := m.Spec.Bootstrap.ConfigRef; isOk3sConfigRef(ref) {
+		return []ctrl.Request{{NamespacedName: client.ObjectKey{Namespace: m.Namespace, Name: ref.Name}}}
+	}
+	return nil
+}
+
+// ClusterToOk3sConfigs maps a Cluster to the Ok3sConfigs of its machines.
+func (r *Ok3sConfigReconciler) ClusterToOk3sConfigs(ctx context.Context, o client.Object) []ctrl.Request {
+	c, ok := o.(*clusterv1.Cluster)
+	if !ok {
+		return nil
+	}
+
+	machines := &clusterv1.MachineList{}
+	if err := r.Client.List(ctx, machines, client.InNamespace(c.Namespace), client.MatchingLabels{clusterv1.ClusterNameLabel: c.Name}); err != nil {
+		r.Log.Error(err, "Failed to list machines of cluster", "cluster", c.Name, "namespace", c.Namespace)
+		return nil
+	}
+
+	var result []ctrl.Request
+	for _, m := range machines.Items {
+		if ref := m.Spec.Bootstrap.ConfigRef; isOk3sConfigRef(ref) {
+			result = append(result, ctrl.Request{NamespacedName: client.ObjectKey{Namespace: m.Namespace, Name: ref.Name}})
+		}
+	}
+	return result
+}
+
+func isOk3sConfigRef(ref *corev1.ObjectReference) bool {
+	return ref != nil && ref.Kind == "Ok3sConfig" && ref.GroupVersionKind().Group == bootstrapv1.GroupVersion.Group
 }
 
 //+kubebuilder:rbac:groups=bootstrap.cluster.x-k8s.io,resources=ok3sconfigs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=bootstrap.cluster.x-k8s.io,resources=ok3sconfigs/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=bootstrap.cluster.x-k8s.io,resources=ok3sconfigs/finalizers,verbs=update
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=get;list;watch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups=exp.cluster.x-k8s.io,resources=machinepools;machinepools/status,verbs=get;list;watch
 
 func (r *Ok3sConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, retErr error) {
-	log := r.Log.WithValues("kthreesconfig", req.NamespacedName)
+	log := r.Log.WithValues("ok3sconfig", req.NamespacedName)
 
 	// Lookup the ok3s config
 	config := &bootstrapv1.Ok3sConfig{}
@@ -216,124 +288,82 @@ func (r *Ok3sConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 }
 
 func (r *Ok3sConfigReconciler) joinControlplane(ctx context.Context, scope *Scope) error {
-	machine := &clusterv1.Machine{}
-	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(scope.ConfigOwner.Object, machine); err != nil {
-		return fmt.Errorf("cannot convert %s to Machine: %w", scope.ConfigOwner.GetKind(), err)
-	}
-
-	// injects into config.Version values from top level object
-	r.reconcileTopLevelObjectSettings(scope.Cluster, machine, scope.Config)
-
-	serverURL := fmt.Sprintf("https://%s", scope.Cluster.Spec.ControlPlaneEndpoint.String())
-
-	token, err := token.Lookup(ctx, r.Client, client.ObjectKeyFromObject(scope.Cluster))
+	userData, err := r.baseUserData(ctx, scope, roles.Server)
 	if err != nil {
-		conditions.MarkFalse(scope.Config, bootstrapv1.DataSecretAvailableCondition, bootstrapv1.DataSecretGenerationFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 		return err
 	}
 
-	configStruct := k3s.GenerateJoinControlPlaneConfig(serverURL, *token,
-		scope.Cluster.Spec.ControlPlaneEndpoint.Host,
-		scope.Config.Spec.ServerConfig,
-		scope.Config.Spec.AgentConfig)
-	b, err := kubeyaml.Marshal(configStruct)
+	input := &cloudinit.ControlPlaneInput{
+		BaseUserData: *userData,
+	}
+	bootstrapData, err := renderBootstrapData(scope.Config, input, cloudinit.NewJoinControlPlane, ignition.NewJoinControlPlane)
 	if err != nil {
 		return err
 	}
 
-	//workerConfigFile := bootstrapv1.File{
-	//	Path:        k3s.DefaultK3sConfigLocation,
-	//	Content:     string(b),
-	//	Owner:       "root:root",
-	//	Permissions: "0640",
-	//}
+	if err := r.storeBootstrapData(ctx, scope, bootstrapData); err != nil {
+		scope.Error(err, "Failed to store bootstrap data")
+		return err
+	}
+	return nil
+}
 
-	files, err := r.resolveFiles(ctx, scope.Config)
+func (r *Ok3sConfigReconciler) joinWorker(ctx context.Context, scope *Scope) error {
+	userData, err := r.baseUserData(ctx, scope, roles.Agent)
 	if err != nil {
-		conditions.MarkFalse(scope.Config, bootstrapv1.DataSecretAvailableCondition, bootstrapv1.DataSecretGenerationFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 		return err
 	}
 
-	cpInput := &cloudinit.ControlPlaneInput{
-		BaseUserData: cloudinit.BaseUserData{
-			PreK3sCommands:  scope.Config.Spec.PreK3sCommands,
-			PostK3sCommands: scope.Config.Spec.PostK3sCommands,
-			AdditionalFiles: files,
-			ConfigFile:      workerConfigFile,
-			K3sVersion:      scope.Config.Spec.Version,
-		},
+	input := &cloudinit.WorkerInput{
+		BaseUserData: *userData,
 	}
-
-	cloudInitData, err := cloudinit.NewJoinControlPlane(cpInput)
+	bootstrapData, err := renderBootstrapData(scope.Config, input, cloudinit.NewWorker, ignition.NewWorker)
 	if err != nil {
 		return err
 	}
 
-	if err := r.storeBootstrapData(ctx, scope, cloudInitData); err != nil {
+	if err := r.storeBootstrapData(ctx, scope, bootstrapData); err != nil {
 		scope.Error(err, "Failed to store bootstrap data")
 		return err
 	}
+
 	return nil
 }
 
-func (r *KThreesConfigReconciler) joinWorker(ctx context.Context, scope *Scope) error {
+// baseUserData returns the user data that installs okr and bootstraps the machine of the
+// config owner with the given role
+func (r *Ok3sConfigReconciler) baseUserData(ctx context.Context, scope *Scope, role string) (*cloudinit.BaseUserData, error) {
 	machine := &clusterv1.Machine{}
 	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(scope.ConfigOwner.Object, machine); err != nil {
-		return fmt.Errorf("cannot convert %s to Machine: %w", scope.ConfigOwner.GetKind(), err)
+		return nil, fmt.Errorf("cannot convert %s to Machine: %w", scope.ConfigOwner.GetKind(), err)
 	}
 
 	// injects into config.Version values from top level object
 	r.reconcileTopLevelObjectSettings(scope.Cluster, machine, scope.Config)
 
-	serverURL := fmt.Sprintf("https://%s", scope.Cluster.Spec.ControlPlaneEndpoint.String())
-
+	// the token secret is created by the control plane provider or by the config of the init
+	// machine, see reconcileToken
 	tokn, err := token.Lookup(ctx, r.Client, client.ObjectKeyFromObject(scope.Cluster))
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = fmt.Errorf("waiting for the control plane provider or the init machine to create the token secret: %w", err)
+		}
 		conditions.MarkFalse(scope.Config, bootstrapv1.DataSecretAvailableCondition, bootstrapv1.DataSecretGenerationFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
-		return err
-	}
-
-	configStruct := k3s.GenerateWorkerConfig(serverURL, *tokn, scope.Config.Spec.ServerConfig, scope.Config.Spec.AgentConfig)
-
-	b, err := kubeyaml.Marshal(configStruct)
-	if err != nil {
-		return err
-	}
-
-	workerConfigFile := bootstrapv1.File{
-		Path:        k3s.DefaultK3sConfigLocation,
-		Content:     string(b),
-		Owner:       "root:root",
-		Permissions: "0640",
-	}
-
-	files, err := r.resolveFiles(ctx, scope.Config)
-	if err != nil {
-		conditions.MarkFalse(scope.Config, bootstrapv1.DataSecretAvailableCondition, bootstrapv1.DataSecretGenerationFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
-		return err
+		return nil, err
 	}
 
-	winput := &cloudinit.WorkerInput{
-		BaseUserData: cloudinit.BaseUserData{
-			PreK3sCommands:  scope.Config.Spec.PreK3sCommands,
-			PostK3sCommands: scope.Config.Spec.PostK3sCommands,
-			AdditionalFiles: files,
-			ConfigFile:      workerConfigFile,
-			K3sVersion:      scope.Config.Spec.Version,
-		},
-	}
-
-	cloudInitData, err := cloudinit.NewWorker(winput)
-	if err != nil {
-		return err
+	// the machine initializing the cluster has no server to join
+	var serverURL string
+	if role != roles.ClusterInit {
+		serverURL = fmt.Sprintf("https://%s", scope.Cluster.Spec.ControlPlaneEndpoint.String())
 	}
 
-	if err := r.storeBootstrapData(ctx, scope, cloudInitData); err != nil {
-		scope.Error(err, "Failed to store bootstrap data")
-		return err
-	}
-
-	return nil
+	return &cloudinit.BaseUserData{
+		PreK3sCommands:  scope.Config.Spec.PreK3sCommands,
+		PostK3sCommands: scope.Config.Spec.PostK3sCommands,
+		Config:          toOKRConfig(&scope.Config.Spec, role, serverURL, *tokn, scope.Cluster.Spec.ControlPlaneEndpoint.Host),
+		OKRVersion:      okrVersion(),
+	}, nil
 }
 
 func (r *Ok3sConfigReconciler) handleClusterNotInitialized(ctx context.Context, scope *Scope) (_ ctrl.Result, reterr error) {
@@ -358,14 +388,14 @@ func (r *Ok3sConfigReconciler) handleClusterNotInitialized(ctx context.Context,
 	// as control plane get processed here
 	// if not the first, requeue
 
-	if !r.KThreesInitLock.Lock(ctx, scope.Cluster, machine) {
+	if !r.K3sInitLock.Lock(ctx, scope.Cluster, machine) {
 		scope.Info("A control plane is already being initialized, requeing until control plane is ready")
 		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
 	}
 
 	defer func() {
 		if reterr != nil {
-			if !r.KThreesInitLock.Unlock(ctx, scope.Cluster) {
+			if !r.K3sInitLock.Unlock(ctx, scope.Cluster) {
 				reterr = kerrors.NewAggregate([]error{reterr, ErrFailedUnlock})
 			}
 		}
@@ -373,15 +403,15 @@ func (r *Ok3sConfigReconciler) handleClusterNotInitialized(ctx context.Context,
 
 	scope.Info("Creating BootstrapData for the init control plane")
 
-	// injects into config.ClusterConfiguration values from top level object
-	r.reconcileTopLevelObjectSettings(scope.Cluster, machine, scope.Config)
-
-	certificates := secret.NewCertificatesForInitialControlPlane(&scope.Config.Spec)
+	// the cluster CA signs the certificates of the runtime as well as the kubeconfig of the cluster
+	certificates := secret.Certificates{
+		&secret.Certificate{Purpose: secret.ClusterCA},
+	}
 	err := certificates.LookupOrGenerate(
 		ctx,
 		r.Client,
 		util.ObjectKey(scope.Cluster),
-		*metav1.NewControllerRef(scope.Config, bootstrapv1.GroupVersion.WithKind("KThreesConfig")),
+		*metav1.NewControllerRef(scope.Config, bootstrapv1.GroupVersion.WithKind("Ok3sConfig")),
 	)
 	if err != nil {
 		conditions.MarkFalse(scope.Config, bootstrapv1.CertificatesAvailableCondition, bootstrapv1.CertificatesGenerationFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
@@ -389,54 +419,27 @@ func (r *Ok3sConfigReconciler) handleClusterNotInitialized(ctx context.Context,
 	}
 	conditions.MarkTrue(scope.Config, bootstrapv1.CertificatesAvailableCondition)
 
-	token, err := token.Lookup(ctx, r.Client, client.ObjectKeyFromObject(scope.Cluster))
-	if err != nil {
-		return ctrl.Result{}, err
-	}
-
-	// TODO support k3s great feature of external backends.
-	// For now just use the etcd option
-	configStruct := k3s.GenerateInitControlPlaneConfig(
-		scope.Cluster.Spec.ControlPlaneEndpoint.Host,
-		*token,
-		scope.Config.Spec.ServerConfig,
-		scope.Config.Spec.AgentConfig)
-
-	b, err := kubeyaml.Marshal(configStruct)
-	if err != nil {
+	if err := r.reconcileToken(ctx, scope); err != nil {
+		conditions.MarkFalse(scope.Config, bootstrapv1.DataSecretAvailableCondition, bootstrapv1.DataSecretGenerationFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 		return ctrl.Result{}, err
 	}
 
-	initConfigFile := bootstrapv1.File{
-		Path:        k3s.DefaultK3sConfigLocation,
-		Content:     string(b),
-		Owner:       "root:root",
-		Permissions: "0640",
-	}
-
-	files, err := r.resolveFiles(ctx, scope.Config)
+	userData, err := r.baseUserData(ctx, scope, roles.ClusterInit)
 	if err != nil {
-		conditions.MarkFalse(scope.Config, bootstrapv1.DataSecretAvailableCondition, bootstrapv1.DataSecretGenerationFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 		return ctrl.Result{}, err
 	}
 
 	cpinput := &cloudinit.ControlPlaneInput{
-		BaseUserData: cloudinit.BaseUserData{
-			PreK3sCommands:  scope.Config.Spec.PreK3sCommands,
-			PostK3sCommands: scope.Config.Spec.PostK3sCommands,
-			AdditionalFiles: files,
-			ConfigFile:      initConfigFile,
-			K3sVersion:      scope.Config.Spec.Version,
-		},
+		BaseUserData: *userData,
 		Certificates: certificates,
 	}
 
-	cloudInitData, err := cloudinit.NewInitControlPlane(cpinput)
+	bootstrapData, err := renderBootstrapData(scope.Config, cpinput, cloudinit.NewInitControlPlane, ignition.NewInitControlPlane)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	if err := r.storeBootstrapData(ctx, scope, cloudInitData); err != nil {
+	if err := r.storeBootstrapData(ctx, scope, bootstrapData); err != nil {
 		scope.Error(err, "Failed to store bootstrap data")
 		return ctrl.Result{}, err
 	}
@@ -445,6 +448,16 @@ func (r *Ok3sConfigReconciler) handleClusterNotInitialized(ctx context.Context,
 	return r.reconcileKubeconfig(ctx, scope)
 }
 
+// reconcileToken creates the token secret of the cluster, owned by the config, when the control
+// plane provider has not created it, e.g. when the config is used without Ok3sControlPlane
+func (r *Ok3sConfigReconciler) reconcileToken(ctx context.Context, scope *Scope) error {
+	key := util.ObjectKey(scope.Cluster)
+	if _, err := token.Lookup(ctx, r.Client, key); !apierrors.IsNotFound(err) {
+		return err
+	}
+	return token.Reconcile(ctx, r.Client, key, scope.Config)
+}
+
 // storeBootstrapData creates a new secret with the data passed in as input,
 // sets the reference in the configuration status and ready to true.
 func (r *Ok3sConfigReconciler) storeBootstrapData(ctx context.Context, scope *Scope, data []byte) error {
@@ -458,7 +471,7 @@ func (r *Ok3sConfigReconciler) storeBootstrapData(ctx context.Context, scope *Sc
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion: bootstrapv1.GroupVersion.String(),
-					Kind:       "KThreesConfig",
+					Kind:       "Ok3sConfig",
 					Name:       scope.Config.Name,
 					UID:        scope.Config.UID,
 					Controller: pointer.Bool(true),
@@ -466,7 +479,8 @@ func (r *Ok3sConfigReconciler) storeBootstrapData(ctx context.Context, scope *Sc
 			},
 		},
 		Data: map[string][]byte{
-			"value": data,
+			"value":  data,
+			"format": []byte(format(scope.Config)),
 		},
 		Type: clusterv1.ClusterSecretType,
 	}
@@ -475,11 +489,11 @@ func (r *Ok3sConfigReconciler) storeBootstrapData(ctx context.Context, scope *Sc
 	// it is possible that secret creation happens but the config.Status patches are not applied
 	if err := r.Client.Create(ctx, secret); err != nil {
 		if !apierrors.IsAlreadyExists(err) {
-			return fmt.Errorf("failed to create bootstrap data secret for KThreesConfig %s/%s: %w", scope.Config.Namespace, scope.Config.Name, err)
+			return fmt.Errorf("failed to create bootstrap data secret for Ok3sConfig %s/%s: %w", scope.Config.Namespace, scope.Config.Name, err)
 		}
-		r.Log.Info("bootstrap data secret for KThreesConfig already exists, updating", "secret", secret.Name, "KThreesConfig", scope.Config.Name)
+		r.Log.Info("bootstrap data secret for Ok3sConfig already exists, updating", "secret", secret.Name, "Ok3sConfig", scope.Config.Name)
 		if err := r.Client.Update(ctx, secret); err != nil {
-			return fmt.Errorf("failed to update bootstrap data secret for KThreesConfig %s/%s: %w", scope.Config.Namespace, scope.Config.Name, err)
+			return fmt.Errorf("failed to update bootstrap data secret for Ok3sConfig %s/%s: %w", scope.Config.Namespace, scope.Config.Name, err)
 		}
 	}
 
@@ -489,6 +503,22 @@ func (r *Ok3sConfigReconciler) storeBootstrapData(ctx context.Context, scope *Sc
 	return nil
 }
 
+// renderBootstrapData renders the input with the renderer of the config's format.
+func renderBootstrapData[T any](config *bootstrapv1.Ok3sConfig, input T, cloudConfig, ignitionConfig func(T) ([]byte, error)) ([]byte, error) {
+	if format(config) == bootstrapv1.Ignition {
+		return ignitionConfig(input)
+	}
+	return cloudConfig(input)
+}
+
+// format returns the format of the bootstrap data of the config, cloud-config unless set.
+func format(config *bootstrapv1.Ok3sConfig) bootstrapv1.Format {
+	if config.Spec.Format == "" {
+		return bootstrapv1.CloudConfig
+	}
+	return config.Spec.Format
+}
+
 func (r *Ok3sConfigReconciler) reconcileKubeconfig(ctx context.Context, scope *Scope) (ctrl.Result, error) {
 	logger := r.Log.WithValues("cluster", scope.Cluster.Name, "namespace", scope.Cluster.Namespace)
 
@@ -510,7 +540,7 @@ func (r *Ok3sConfigReconciler) reconcileKubeconfig(ctx context.Context, scope *S
 }
 
 func (r *Ok3sConfigReconciler) reconcileTopLevelObjectSettings(_ *clusterv1.Cluster, machine *clusterv1.Machine, config *bootstrapv1.Ok3sConfig) {
-	log := r.Log.WithValues("kthreesconfig", fmt.Sprintf("%s/%s", config.Namespace, config.Name))
+	log := r.Log.WithValues("ok3sconfig", fmt.Sprintf("%s/%s", config.Namespace, config.Name))
 
 	// If there are no Version settings defined in Config, use Version from machine, if defined
 	if config.Spec.Version == "" && machine.Spec.Version != nil {
@@ -0,0 +1,160 @@
+package bootstrap
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	bootstrapv1 "github.com/oneblock-ai/okr/api/bootstrap/v1"
+	"github.com/oneblock-ai/okr/pkg/token"
+)
+
+func newMachine(name, cluster string, ref *corev1.ObjectReference) *clusterv1.Machine {
+	return &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: cluster},
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: cluster,
+			Bootstrap:   clusterv1.Bootstrap{ConfigRef: ref},
+		},
+	}
+}
+
+func configRef(kind, name string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: bootstrapv1.GroupVersion.String(),
+		Kind:       kind,
+		Name:       name,
+	}
+}
+
+func request(name string) ctrl.Request {
+	return ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: name}}
+}
+
+func TestMapFuncs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	machines := []client.Object{
+		newMachine("cp", "test", configRef("Ok3sConfig", "cp-config")),
+		newMachine("worker", "test", configRef("Ok3sConfig", "worker-config")),
+		newMachine("other-kind", "test", configRef("KubeadmConfig", "kubeadm-config")),
+		newMachine("no-ref", "test", nil),
+		newMachine("other-cluster", "other", configRef("Ok3sConfig", "other-config")),
+	}
+	r := &Ok3sConfigReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(machines...).Build(),
+		Log:    ctrl.Log,
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		object   client.Object
+		mapFunc  func(context.Context, client.Object) []ctrl.Request
+		expected []ctrl.Request
+	}{
+		{name: "machine", object: machines[0], mapFunc: r.MachineToBootstrapMapFunc, expected: []ctrl.Request{request("cp-config")}},
+		{name: "machine of another provider", object: machines[2], mapFunc: r.MachineToBootstrapMapFunc},
+		{name: "machine without config", object: machines[3], mapFunc: r.MachineToBootstrapMapFunc},
+		{name: "not a machine", object: &clusterv1.Cluster{}, mapFunc: r.MachineToBootstrapMapFunc},
+		{
+			name:     "cluster",
+			object:   &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}},
+			mapFunc:  r.ClusterToOk3sConfigs,
+			expected: []ctrl.Request{request("cp-config"), request("worker-config")},
+		},
+		{name: "not a cluster", object: machines[0], mapFunc: r.ClusterToOk3sConfigs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if requests := tt.mapFunc(ctx, tt.object); !reflect.DeepEqual(requests, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, requests)
+			}
+		})
+	}
+}
+
+func TestReconcileToken(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := bootstrapv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}}
+	config := &bootstrapv1.Ok3sConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cp-config", UID: "config-uid"}}
+	isController := true
+	providerSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "test-token",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "controlplane.cluster.x-k8s.io/v1",
+				Kind:       "Ok3sControlPlane",
+				Name:       "test-cp",
+				UID:        "cp-uid",
+				Controller: &isController,
+			}},
+		},
+		Data: map[string][]byte{"value": []byte("provider-token")},
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		objects []client.Object
+		owner   types.UID
+		token   string
+	}{
+		{name: "created without a control plane provider", owner: config.UID},
+		{name: "kept from the control plane provider", objects: []client.Object{providerSecret}, owner: "cp-uid", token: "provider-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.objects...).Build()
+			r := &Ok3sConfigReconciler{Client: c, Log: ctrl.Log}
+			scope := &Scope{Logger: ctrl.Log, Config: config, Cluster: cluster}
+
+			if err := r.reconcileToken(ctx, scope); err != nil {
+				t.Fatal(err)
+			}
+
+			tokn, err := token.Lookup(ctx, c, client.ObjectKeyFromObject(cluster))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.token != "" && *tokn != tt.token {
+				t.Errorf("expected token %q, got %q", tt.token, *tokn)
+			}
+			s := &corev1.Secret{}
+			if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "test-token"}, s); err != nil {
+				t.Fatal(err)
+			}
+			if ref := metav1.GetControllerOf(s); ref == nil || ref.UID != tt.owner {
+				t.Errorf("expected the secret to be controlled by %s, got %v", tt.owner, s.OwnerReferences)
+			}
+		})
+	}
+}
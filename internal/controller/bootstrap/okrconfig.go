@@ -0,0 +1,81 @@
+package bootstrap
+
+import (
+	bootstrapv1 "github.com/oneblock-ai/okr/api/bootstrap/v1"
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/roles"
+	"github.com/oneblock-ai/okr/pkg/version"
+)
+
+// toOKRConfig returns the okr config that bootstraps a machine of the given role from the spec,
+// server is the URL of the control plane endpoint, it is not set on the machine initializing the cluster
+func toOKRConfig(spec *bootstrapv1.Ok3sConfigSpec, role, server, token, endpointHost string) config.Config {
+	cfg := config.Config{
+		RuntimeConfig: config.RuntimeConfig{
+			Server:       server,
+			Role:         role,
+			Token:        token,
+			NodeName:     spec.AgentConfig.NodeName,
+			Labels:       spec.AgentConfig.NodeLabels,
+			Taints:       spec.AgentConfig.NodeTaints,
+			ConfigValues: map[string]interface{}{},
+		},
+		KubernetesVersion: spec.Version,
+	}
+
+	kubeletArgs := append([]string{}, spec.AgentConfig.KubeletArgs...)
+	if !spec.ServerConfig.DisableExternalCloudProvider {
+		kubeletArgs = append(kubeletArgs, "cloud-provider=external")
+	}
+
+	values := cfg.ConfigValues
+	setList(values, "kubelet-arg", kubeletArgs)
+	setList(values, "kube-proxy-arg", spec.AgentConfig.KubeProxyArgs)
+	setValue(values, "private-registry", spec.AgentConfig.PrivateRegistry)
+
+	if role == roles.Agent {
+		return cfg
+	}
+
+	serverConfig := spec.ServerConfig
+	cfg.SANS = append([]string{}, serverConfig.TLSSan...)
+	if endpointHost != "" {
+		cfg.SANS = append(cfg.SANS, endpointHost)
+	}
+	setList(values, "kube-apiserver-arg", serverConfig.KubeAPIServerArgs)
+	setList(values, "kube-controller-manager-arg", serverConfig.KubeControllerManagerArgs)
+	setList(values, "kube-scheduler-arg", serverConfig.KubeSchedulerArgs)
+	setList(values, "disable", serverConfig.DisableComponents)
+	setValue(values, "bind-address", serverConfig.BindAddress)
+	setValue(values, "https-listen-port", serverConfig.HTTPSListenPort)
+	setValue(values, "advertise-address", serverConfig.AdvertiseAddress)
+	setValue(values, "advertise-port", serverConfig.AdvertisePort)
+	setValue(values, "cluster-cidr", serverConfig.ClusterCidr)
+	setValue(values, "service-cidr", serverConfig.ServiceCidr)
+	setValue(values, "cluster-dns", serverConfig.ClusterDNS)
+	setValue(values, "cluster-domain", serverConfig.ClusterDomain)
+	if !serverConfig.DisableExternalCloudProvider {
+		values["disable-cloud-controller"] = true
+	}
+	return cfg
+}
+
+func setList(values map[string]interface{}, key string, list []string) {
+	if len(list) > 0 {
+		values[key] = list
+	}
+}
+
+func setValue(values map[string]interface{}, key, value string) {
+	if value != "" {
+		values[key] = value
+	}
+}
+
+// okrVersion is the okr release machines install, development builds install the latest release
+func okrVersion() string {
+	if version.Version == "dev" {
+		return ""
+	}
+	return version.Version
+}
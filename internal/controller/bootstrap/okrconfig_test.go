@@ -0,0 +1,114 @@
+package bootstrap
+
+import (
+	"reflect"
+	"testing"
+
+	bootstrapv1 "github.com/oneblock-ai/okr/api/bootstrap/v1"
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/roles"
+)
+
+func TestToOKRConfig(t *testing.T) {
+	spec := &bootstrapv1.Ok3sConfigSpec{
+		Version: "v1.28.4+k3s2",
+		ServerConfig: bootstrapv1.KThreesServerConfig{
+			TLSSan:            []string{"k8s.example.com"},
+			KubeAPIServerArgs: []string{"anonymous-auth=false"},
+			DisableComponents: []string{"traefik"},
+			ClusterCidr:       "10.42.0.0/16",
+		},
+		AgentConfig: bootstrapv1.KThreesAgentConfig{
+			NodeName:    "node1",
+			NodeLabels:  []string{"zone=a"},
+			NodeTaints:  []string{"gpu=true:NoSchedule"},
+			KubeletArgs: []string{"max-pods=200"},
+		},
+	}
+	agentValues := map[string]interface{}{
+		"kubelet-arg": []string{"max-pods=200", "cloud-provider=external"},
+	}
+	serverValues := map[string]interface{}{
+		"kubelet-arg":              []string{"max-pods=200", "cloud-provider=external"},
+		"kube-apiserver-arg":       []string{"anonymous-auth=false"},
+		"disable":                  []string{"traefik"},
+		"cluster-cidr":             "10.42.0.0/16",
+		"disable-cloud-controller": true,
+	}
+
+	tests := []struct {
+		name   string
+		role   string
+		server string
+		sans   []string
+		values map[string]interface{}
+	}{
+		{
+			name:   "init",
+			role:   roles.ClusterInit,
+			sans:   []string{"k8s.example.com", "10.0.0.1"},
+			values: serverValues,
+		},
+		{
+			name:   "join",
+			role:   roles.Server,
+			server: "https://10.0.0.1:6443",
+			sans:   []string{"k8s.example.com", "10.0.0.1"},
+			values: serverValues,
+		},
+		{
+			name:   "worker",
+			role:   roles.Agent,
+			server: "https://10.0.0.1:6443",
+			values: agentValues,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := toOKRConfig(spec, tt.role, tt.server, "token", "10.0.0.1")
+
+			expected := config.RuntimeConfig{
+				Server:       tt.server,
+				Role:         tt.role,
+				Token:        "token",
+				NodeName:     "node1",
+				Labels:       []string{"zone=a"},
+				Taints:       []string{"gpu=true:NoSchedule"},
+				SANS:         tt.sans,
+				ConfigValues: tt.values,
+			}
+			if !reflect.DeepEqual(cfg.RuntimeConfig, expected) {
+				t.Errorf("expected\n%+v\ngot\n%+v", expected, cfg.RuntimeConfig)
+			}
+			if cfg.KubernetesVersion != spec.Version {
+				t.Errorf("expected version %s, got %s", spec.Version, cfg.KubernetesVersion)
+			}
+		})
+	}
+}
+
+func TestToOKRConfigWithoutExternalCloudProvider(t *testing.T) {
+	spec := &bootstrapv1.Ok3sConfigSpec{
+		ServerConfig: bootstrapv1.KThreesServerConfig{DisableExternalCloudProvider: true},
+	}
+	for _, role := range []string{roles.ClusterInit, roles.Agent} {
+		cfg := toOKRConfig(spec, role, "", "token", "")
+		if len(cfg.ConfigValues) != 0 {
+			t.Errorf("%s: expected no config values, got %v", role, cfg.ConfigValues)
+		}
+		if len(cfg.SANS) != 0 {
+			t.Errorf("%s: expected no tls-san without an endpoint, got %v", role, cfg.SANS)
+		}
+	}
+}
+
+func TestToOKRConfigDoesNotChangeSpec(t *testing.T) {
+	spec := &bootstrapv1.Ok3sConfigSpec{
+		ServerConfig: bootstrapv1.KThreesServerConfig{TLSSan: make([]string, 1, 4)},
+		AgentConfig:  bootstrapv1.KThreesAgentConfig{KubeletArgs: make([]string, 1, 4)},
+	}
+	toOKRConfig(spec, roles.ClusterInit, "", "token", "10.0.0.1")
+	if spec.ServerConfig.TLSSan[:2][1] != "" || spec.AgentConfig.KubeletArgs[:2][1] != "" {
+		t.Error("expected the spec's slices not to be appended to")
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
@@ -38,6 +39,8 @@ import (
 	controlplanev1 "github.com/oneblock-ai/okr/api/controlplane/v1"
 	"github.com/oneblock-ai/okr/pkg/scope"
 	"github.com/oneblock-ai/okr/pkg/services"
+	"github.com/oneblock-ai/okr/pkg/services/machines"
+	"github.com/oneblock-ai/okr/pkg/token"
 )
 
 // Reconciler reconciles a Ok3sControlPlane object
@@ -51,9 +54,9 @@ type Reconciler struct {
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, ctx context.Context) error {
 	logger := log.FromContext(ctx)
 
-	controlPlane := &controlplanev1.Ok3sControlPlane{}
 	c, err := ctrl.NewControllerManagedBy(mgr).
-		For(controlPlane).
+		For(&controlplanev1.Ok3sControlPlane{}).
+		Owns(&clusterv1.Machine{}).
 		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(logger, r.WatchFilterValue)).
 		Build(r)
 
@@ -62,8 +65,8 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, ctx context.Context) err
 	}
 
 	if err = c.Watch(
-		source.Kind(nil, &clusterv1.Cluster{}),
-		handler.EnqueueRequestsFromMapFunc(util.ClusterToInfrastructureMapFunc(ctx, controlPlane.GroupVersionKind(), mgr.GetClient(), &controlplanev1.Ok3sControlPlane{})),
+		source.Kind(mgr.GetCache(), &clusterv1.Cluster{}),
+		handler.EnqueueRequestsFromMapFunc(r.ClusterToOk3sControlPlane),
 		predicates.ClusterUnpausedAndInfrastructureReady(logger),
 	); err != nil {
 		return fmt.Errorf("failed adding a watch for ready clusters: %w", err)
@@ -72,14 +75,31 @@ func (r *Reconciler) SetupWithManager(mgr ctrl.Manager, ctx context.Context) err
 	return nil
 }
 
+// ClusterToOk3sControlPlane maps a Cluster to the Ok3sControlPlane of its control plane reference.
+func (r *Reconciler) ClusterToOk3sControlPlane(_ context.Context, o client.Object) []ctrl.Request {
+	c, ok := o.(*clusterv1.Cluster)
+	if !ok {
+		return nil
+	}
+	ref := c.Spec.ControlPlaneRef
+	if ref == nil || ref.Kind != "Ok3sControlPlane" || ref.GroupVersionKind().Group != controlplanev1.GroupVersion.Group {
+		return nil
+	}
+	return []ctrl.Request{{NamespacedName: client.ObjectKey{Namespace: c.Namespace, Name: ref.Name}}}
+}
+
 //+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=ok3scontrolplanes,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=ok3scontrolplanes/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=controlplane.cluster.x-k8s.io,resources=ok3scontrolplanes/finalizers,verbs=update
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=bootstrap.cluster.x-k8s.io,resources=ok3sconfigs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=*,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile the Ok3sControlPlane object against the actual cluster state, and then
 // perform operations to make the current cluster state closer to the desired state.
-func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
 	logger := log.FromContext(ctx)
 
 	// TODO(user): your logic here
@@ -125,9 +145,10 @@ func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 		return reconcile.Result{}, fmt.Errorf("failed to create scope: %w", err)
 	}
 
+	// always patch the status and conditions of the control plane
 	defer func() {
-		//TODO: update conditions if needed
-		if err = cpScope.Close(); err != nil {
+		if err := cpScope.Close(); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, fmt.Errorf("failed to patch Ok3sControlPlane: %w", err)})
 		}
 	}()
 
@@ -151,8 +172,13 @@ func (r *Reconciler) reconcileNormal(ctx context.Context, cpScope *scope.Control
 		}
 	}
 
+	// the bootstrap configs of the machines join them to the cluster with this token
+	if err := token.Reconcile(ctx, r.Client, util.ObjectKey(cpScope.Cluster), cpScope.ControlPlane); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to reconcile the token secret: %w", err)
+	}
+
 	reconcilers := []services.ReconcilerWithResult{
-		//cluster.NewService(cpScope),
+		machines.NewService(cpScope),
 	}
 
 	for _, r := range reconcilers {
@@ -174,7 +200,7 @@ func (r *Reconciler) reconcileDelete(ctx context.Context, cpScope *scope.Control
 	cpScope.Logger.Info("Reconciling Ok3sControlPlane delete")
 
 	reconcilers := []services.ReconcilerWithResult{
-		//cluster.NewService(cpScope),
+		machines.NewService(cpScope),
 	}
 
 	for _, r := range reconcilers {
@@ -0,0 +1,59 @@
+package controlplane
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	controlplanev1 "github.com/oneblock-ai/okr/api/controlplane/v1"
+)
+
+func newCluster(ref *corev1.ObjectReference) *clusterv1.Cluster {
+	return &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"},
+		Spec:       clusterv1.ClusterSpec{ControlPlaneRef: ref},
+	}
+}
+
+func TestClusterToOk3sControlPlane(t *testing.T) {
+	r := &Reconciler{}
+
+	tests := []struct {
+		name     string
+		object   client.Object
+		expected []ctrl.Request
+	}{
+		{
+			name: "cluster",
+			object: newCluster(&corev1.ObjectReference{
+				APIVersion: controlplanev1.GroupVersion.String(),
+				Kind:       "Ok3sControlPlane",
+				Name:       "test-cp",
+			}),
+			expected: []ctrl.Request{{NamespacedName: client.ObjectKey{Namespace: "default", Name: "test-cp"}}},
+		},
+		{
+			name: "control plane of another provider",
+			object: newCluster(&corev1.ObjectReference{
+				APIVersion: "controlplane.cluster.x-k8s.io/v1beta1",
+				Kind:       "KubeadmControlPlane",
+				Name:       "test-cp",
+			}),
+		},
+		{name: "cluster without control plane", object: newCluster(nil)},
+		{name: "not a cluster", object: &clusterv1.Machine{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if requests := r.ClusterToOk3sControlPlane(context.Background(), tt.object); !reflect.DeepEqual(requests, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package locking
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newMachine(name string) *clusterv1.Machine {
+	return &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+}
+
+func TestControlPlaneInitMutex(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := clusterv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}}
+	first, second := newMachine("first"), newMachine("second")
+	client := fake.NewClientBuilder().WithScheme(scheme).WithObjects(first, second).Build()
+	mutex := NewControlPlaneInitMutex(ctrl.Log, client)
+
+	if !mutex.Lock(ctx, cluster, first) {
+		t.Fatal("expected the first machine to acquire the lock")
+	}
+	if !mutex.Lock(ctx, cluster, first) {
+		t.Error("expected the lock to be held by the first machine on retry")
+	}
+	if mutex.Lock(ctx, cluster, second) {
+		t.Error("expected the second machine not to acquire the lock held by the first machine")
+	}
+
+	// the lock of a deleted machine is released and acquired on the next attempt
+	if err := client.Delete(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if mutex.Lock(ctx, cluster, second) {
+		t.Error("expected the stale lock to be released without acquiring it")
+	}
+	if !mutex.Lock(ctx, cluster, second) {
+		t.Error("expected the second machine to acquire the released lock")
+	}
+
+	if !mutex.Unlock(ctx, cluster) {
+		t.Error("expected unlock to succeed")
+	}
+	if !mutex.Unlock(ctx, cluster) {
+		t.Error("expected unlocking a released lock to succeed")
+	}
+}
@@ -129,6 +129,7 @@ func setupProbes(mgr ctrl.Manager) {
 func setupReconcilers(ctx context.Context, mgr ctrl.Manager) {
 	if err := (&bootstrap.Ok3sConfigReconciler{
 		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("ok3sconfig"),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr, ctx); err != nil {
 		setupLog.Error(err, "unable to create bootstrap", "bootstrap", "Ok3sConfig")
@@ -0,0 +1,139 @@
+package cloudinit
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/rancher/wrangler/v2/pkg/data/convert"
+	"sigs.k8s.io/yaml"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	runtime2 "github.com/oneblock-ai/okr/pkg/k3s/instructions/runtime"
+)
+
+const (
+	// ConfigFile is where the okr config of the machine is written to
+	ConfigFile = "/etc/oneblock-ai/okr/config.yaml"
+	// InstallScriptURL is the okr installer run on first boot
+	InstallScriptURL = "https://raw.githubusercontent.com/oneblock-ai/okr/main/install.sh"
+
+	cloudConfigHeader = "#cloud-config\n"
+)
+
+// File is a file written before okr is installed
+type File struct {
+	Path        string `json:"path"`
+	Content     string `json:"content"`
+	Owner       string `json:"owner,omitempty"`
+	Permissions string `json:"permissions,omitempty"`
+}
+
+// BaseUserData is the user data shared by all machines
+type BaseUserData struct {
+	// PreK3sCommands run before okr is installed
+	PreK3sCommands []string
+	// PostK3sCommands run once okr bootstrap has finished
+	PostK3sCommands []string
+	AdditionalFiles []File
+	// Config is written to ConfigFile and bootstraps the machine
+	Config config.Config
+	// OKRVersion is the okr release to install, the latest release if empty
+	OKRVersion string
+}
+
+type cloudConfig struct {
+	WriteFiles []File   `json:"write_files,omitempty"`
+	RunCmd     []string `json:"runcmd,omitempty"`
+}
+
+// Files returns the okr config and the additional files of the machine
+func (b *BaseUserData) Files() ([]File, error) {
+	data, err := configYAML(&b.Config)
+	if err != nil {
+		return nil, err
+	}
+	files := []File{
+		{
+			Path:        ConfigFile,
+			Content:     string(data),
+			Owner:       "root:root",
+			Permissions: "0600",
+		},
+	}
+	return append(files, b.AdditionalFiles...), nil
+}
+
+// Commands returns the commands run on first boot: the pre commands, the okr install, okr
+// bootstrap, the start of okr agent and the post commands. The installer only enables the
+// services, so that the post commands run after bootstrap finished, the services bootstrap
+// the machine and run the agent on later boots
+func (b *BaseUserData) Commands() []string {
+	var commands []string
+	commands = append(commands, b.PreK3sCommands...)
+	commands = append(commands,
+		b.InstallCommand("INSTALL_OKR_SKIP_START=true", "INSTALL_OKR_ENABLE_AGENT=true"),
+		"/usr/local/bin/okr bootstrap",
+		"systemctl start --no-block okr-agent",
+	)
+	return append(commands, b.PostK3sCommands...)
+}
+
+// InstallCommand returns the command that installs okr with the installer settings in env
+func (b *BaseUserData) InstallCommand(env ...string) string {
+	if b.OKRVersion != "" {
+		env = append(env, "INSTALL_OKR_VERSION="+b.OKRVersion)
+	}
+	return fmt.Sprintf("curl -sfL %s | %s sh -", InstallScriptURL, strings.Join(env, " "))
+}
+
+// DataDir returns the data dir of the runtime the machine is bootstrapped with
+func (b *BaseUserData) DataDir() string {
+	runtime := config.GetRuntime(b.Config.KubernetesVersion)
+	if runtime == config.RuntimeUnknown {
+		runtime = config.RuntimeK3S
+	}
+	return runtime2.GetDataDir(runtime)
+}
+
+func render(input *BaseUserData, files []File) ([]byte, error) {
+	baseFiles, err := input.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := yaml.Marshal(cloudConfig{
+		WriteFiles: append(baseFiles, files...),
+		RunCmd:     input.Commands(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rendering cloud-config: %w", err)
+	}
+	return append([]byte(cloudConfigHeader), data...), nil
+}
+
+// configYAML renders the okr config without the sections left empty
+func configYAML(cfg *config.Config) ([]byte, error) {
+	data, err := convert.EncodeToMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dropEmpty(data)
+	return yaml.Marshal(data)
+}
+
+// dropEmpty removes the sections of data that are empty or only hold empty sections
+func dropEmpty(data map[string]interface{}) {
+	for k, v := range data {
+		if m, ok := v.(map[string]interface{}); ok {
+			dropEmpty(m)
+			if len(m) == 0 {
+				delete(data, k)
+			}
+		}
+	}
+}
+
+func tlsFile(dataDir, name string) string {
+	return filepath.Join(dataDir, "server", "tls", name)
+}
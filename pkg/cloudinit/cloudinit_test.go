@@ -0,0 +1,163 @@
+package cloudinit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/cluster-api/util/certs"
+	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/yaml"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+)
+
+func baseUserData(role string) BaseUserData {
+	return BaseUserData{
+		PreK3sCommands:  []string{"echo pre"},
+		PostK3sCommands: []string{"echo post"},
+		AdditionalFiles: []File{{Path: "/etc/extra", Content: "extra"}},
+		Config: config.Config{
+			RuntimeConfig: config.RuntimeConfig{
+				Role:  role,
+				Token: "token",
+			},
+			KubernetesVersion: "v1.28.4+k3s2",
+		},
+		OKRVersion: "v0.1.0",
+	}
+}
+
+func clusterCA() secret.Certificates {
+	return secret.Certificates{
+		&secret.Certificate{
+			Purpose: secret.ClusterCA,
+			KeyPair: &certs.KeyPair{Cert: []byte("ca-cert"), Key: []byte("ca-key")},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		render func() ([]byte, error)
+		role   string
+		files  []string
+	}{
+		{
+			name: "init",
+			render: func() ([]byte, error) {
+				return NewInitControlPlane(&ControlPlaneInput{BaseUserData: baseUserData("cluster-init"), Certificates: clusterCA()})
+			},
+			role: "cluster-init",
+			files: []string{
+				ConfigFile,
+				"/etc/extra",
+				"/var/lib/rancher/k3s/server/tls/server-ca.crt",
+				"/var/lib/rancher/k3s/server/tls/server-ca.key",
+				"/var/lib/rancher/k3s/server/tls/client-ca.crt",
+				"/var/lib/rancher/k3s/server/tls/client-ca.key",
+			},
+		},
+		{
+			name: "join",
+			render: func() ([]byte, error) {
+				return NewJoinControlPlane(&ControlPlaneInput{BaseUserData: baseUserData("server"), Certificates: clusterCA()})
+			},
+			role:  "server",
+			files: []string{ConfigFile, "/etc/extra"},
+		},
+		{
+			name: "worker",
+			render: func() ([]byte, error) {
+				return NewWorker(&WorkerInput{BaseUserData: baseUserData("agent")})
+			},
+			role:  "agent",
+			files: []string{ConfigFile, "/etc/extra"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.render()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(data), "#cloud-config\n") {
+				t.Fatalf("expected the #cloud-config header, got\n%s", data)
+			}
+
+			rendered := cloudConfig{}
+			if err := yaml.Unmarshal(data, &rendered); err != nil {
+				t.Fatal(err)
+			}
+
+			var paths []string
+			for _, file := range rendered.WriteFiles {
+				paths = append(paths, file.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.files) {
+				t.Errorf("expected the files %v, got %v", tt.files, paths)
+			}
+
+			cfg := rendered.WriteFiles[0]
+			if cfg.Permissions != "0600" || cfg.Owner != "root:root" {
+				t.Errorf("expected the config to be only readable by root, got %s %s", cfg.Owner, cfg.Permissions)
+			}
+			if !strings.Contains(cfg.Content, "role: "+tt.role) {
+				t.Errorf("expected the role %s in the config, got\n%s", tt.role, cfg.Content)
+			}
+
+			expected := []string{
+				"echo pre",
+				"curl -sfL " + InstallScriptURL + " | INSTALL_OKR_SKIP_START=true INSTALL_OKR_ENABLE_AGENT=true INSTALL_OKR_VERSION=v0.1.0 sh -",
+				"/usr/local/bin/okr bootstrap",
+				"systemctl start --no-block okr-agent",
+				"echo post",
+			}
+			if !reflect.DeepEqual(rendered.RunCmd, expected) {
+				t.Errorf("expected the commands\n%v\ngot\n%v", expected, rendered.RunCmd)
+			}
+		})
+	}
+}
+
+func TestCertificateFiles(t *testing.T) {
+	input := &ControlPlaneInput{BaseUserData: baseUserData("cluster-init"), Certificates: clusterCA()}
+	input.Config.KubernetesVersion = "v1.28.4+rke2r1"
+
+	files := input.CertificateFiles()
+	if len(files) != 4 {
+		t.Fatalf("expected 4 files, got %+v", files)
+	}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Path, "/var/lib/rancher/rke2/server/tls/") {
+			t.Errorf("expected %s in the data dir of rke2", file.Path)
+		}
+		key := strings.HasSuffix(file.Path, ".key")
+		if key && (file.Content != "ca-key" || file.Permissions != "0600") {
+			t.Errorf("expected the CA key with 0600 in %s, got %s", file.Path, file.Permissions)
+		}
+		if !key && (file.Content != "ca-cert" || file.Permissions != "0640") {
+			t.Errorf("expected the CA certificate with 0640 in %s, got %s", file.Path, file.Permissions)
+		}
+	}
+
+	if files := (&ControlPlaneInput{}).CertificateFiles(); files != nil {
+		t.Errorf("expected no files without a cluster CA, got %+v", files)
+	}
+}
+
+func TestConfigYAMLOmitsEmptySections(t *testing.T) {
+	data, err := configYAML(&config.Config{
+		RuntimeConfig:     config.RuntimeConfig{Role: "agent"},
+		KubernetesVersion: "v1.28.4+k3s2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, section := range []string{"channels", "kuberay", "airgap", "retryPolicy"} {
+		if strings.Contains(string(data), section+":") {
+			t.Errorf("expected no empty %s section, got\n%s", section, data)
+		}
+	}
+}
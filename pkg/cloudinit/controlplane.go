@@ -0,0 +1,52 @@
+package cloudinit
+
+import (
+	"sigs.k8s.io/cluster-api/util/secret"
+)
+
+// ControlPlaneInput is the user data of a control plane machine
+type ControlPlaneInput struct {
+	BaseUserData
+	// Certificates holds the cluster CA the runtime signs its server and client certificates with
+	Certificates secret.Certificates
+}
+
+// NewInitControlPlane returns the user data of the machine that initializes the cluster
+func NewInitControlPlane(input *ControlPlaneInput) ([]byte, error) {
+	return render(&input.BaseUserData, input.CertificateFiles())
+}
+
+// NewJoinControlPlane returns the user data of a control plane machine joining the cluster,
+// the runtime fetches the cluster CA from the cluster on join
+func NewJoinControlPlane(input *ControlPlaneInput) ([]byte, error) {
+	return render(&input.BaseUserData, nil)
+}
+
+// CertificateFiles writes the cluster CA as the server and client CA of the runtime, so that
+// the kubeconfig Cluster API signs with the cluster CA is accepted by the apiserver
+func (c *ControlPlaneInput) CertificateFiles() []File {
+	ca := c.Certificates.GetByPurpose(secret.ClusterCA)
+	if ca == nil || ca.KeyPair == nil {
+		return nil
+	}
+
+	dataDir := c.DataDir()
+	var files []File
+	for _, name := range []string{"server-ca", "client-ca"} {
+		files = append(files,
+			File{
+				Path:        tlsFile(dataDir, name+".crt"),
+				Content:     string(ca.KeyPair.Cert),
+				Owner:       "root:root",
+				Permissions: "0640",
+			},
+			File{
+				Path:        tlsFile(dataDir, name+".key"),
+				Content:     string(ca.KeyPair.Key),
+				Owner:       "root:root",
+				Permissions: "0600",
+			},
+		)
+	}
+	return files
+}
@@ -0,0 +1,11 @@
+package cloudinit
+
+// WorkerInput is the user data of a worker machine
+type WorkerInput struct {
+	BaseUserData
+}
+
+// NewWorker returns the user data of a worker machine joining the cluster
+func NewWorker(input *WorkerInput) ([]byte, error) {
+	return render(&input.BaseUserData, nil)
+}
@@ -0,0 +1,235 @@
+package ignition
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/oneblock-ai/okr/pkg/cloudinit"
+)
+
+const (
+	// Version is the Ignition config spec version rendered
+	Version = "3.3.0"
+
+	binDir        = "/opt/bin"
+	scriptDir     = "/opt/oneblock-ai/okr"
+	installScript = scriptDir + "/install.sh"
+	postScript    = scriptDir + "/post.sh"
+	postStamp     = "/var/lib/oneblock-ai/okr/post-commands.done"
+	// installerSystemdDir receives the units written by the installer, okr.service and
+	// okr-agent.service are part of the Ignition config instead
+	installerSystemdDir = "/run/okr-installer"
+)
+
+// installUnit installs okr once, before the first okr bootstrap
+const installUnit = `[Unit]
+Description=OKR Install
+Documentation=https://github.com/oneblock-ai/okr
+Wants=network-online.target
+After=network-online.target
+ConditionPathExists=!` + binDir + `/okr
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+TimeoutStartSec=0
+ExecStart=` + installScript + `
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// okrUnit is the okr.service of install.sh with okr installed to binDir
+const okrUnit = `[Unit]
+Description=OKR Bootstrap
+Documentation=https://github.com/oneblock-ai/okr
+Wants=network-online.target
+After=network-online.target okr-install.service
+Requires=okr-install.service
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+KillMode=process
+LimitNOFILE=1048576
+LimitNPROC=infinity
+LimitCORE=infinity
+TasksMax=infinity
+TimeoutStartSec=0
+ExecStart=` + binDir + `/okr bootstrap
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// agentUnit is the okr-agent.service of install.sh with okr installed to binDir
+const agentUnit = `[Unit]
+Description=OKR Agent
+Documentation=https://github.com/oneblock-ai/okr
+Wants=network-online.target
+After=network-online.target okr.service
+
+[Service]
+Type=simple
+KillMode=process
+Restart=always
+RestartSec=5s
+ExecStart=` + binDir + `/okr agent
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// postUnit runs the post commands once, after the first okr bootstrap
+const postUnit = `[Unit]
+Description=OKR post bootstrap commands
+After=okr.service
+Requires=okr.service
+ConditionPathExists=!` + postStamp + `
+
+[Service]
+Type=oneshot
+RemainAfterExit=yes
+TimeoutStartSec=0
+ExecStart=` + postScript + `
+
+[Install]
+WantedBy=multi-user.target
+`
+
+type config struct {
+	Ignition ignition `json:"ignition"`
+	Storage  storage  `json:"storage,omitempty"`
+	Systemd  systemd  `json:"systemd,omitempty"`
+}
+
+type ignition struct {
+	Version string `json:"version"`
+}
+
+type storage struct {
+	Files []file `json:"files,omitempty"`
+}
+
+type file struct {
+	Path      string   `json:"path"`
+	Overwrite bool     `json:"overwrite"`
+	Mode      *int     `json:"mode,omitempty"`
+	User      *owner   `json:"user,omitempty"`
+	Group     *owner   `json:"group,omitempty"`
+	Contents  contents `json:"contents"`
+}
+
+type owner struct {
+	Name string `json:"name"`
+}
+
+type contents struct {
+	Source string `json:"source"`
+}
+
+type systemd struct {
+	Units []unit `json:"units,omitempty"`
+}
+
+type unit struct {
+	Name     string `json:"name"`
+	Enabled  bool   `json:"enabled"`
+	Contents string `json:"contents"`
+}
+
+// NewInitControlPlane returns the Ignition config of the machine that initializes the cluster
+func NewInitControlPlane(input *cloudinit.ControlPlaneInput) ([]byte, error) {
+	return render(&input.BaseUserData, input.CertificateFiles())
+}
+
+// NewJoinControlPlane returns the Ignition config of a control plane machine joining the cluster
+func NewJoinControlPlane(input *cloudinit.ControlPlaneInput) ([]byte, error) {
+	return render(&input.BaseUserData, nil)
+}
+
+// NewWorker returns the Ignition config of a worker machine joining the cluster
+func NewWorker(input *cloudinit.WorkerInput) ([]byte, error) {
+	return render(&input.BaseUserData, nil)
+}
+
+func render(input *cloudinit.BaseUserData, extraFiles []cloudinit.File) ([]byte, error) {
+	files, err := input.Files()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, extraFiles...)
+
+	install := append([]string{"mkdir -p " + binDir + " " + installerSystemdDir}, input.PreK3sCommands...)
+	install = append(install, input.InstallCommand(
+		"INSTALL_OKR_SKIP_ENABLE=true",
+		"INSTALL_OKR_BIN_DIR="+binDir,
+		"INSTALL_OKR_SYSTEMD_DIR="+installerSystemdDir,
+	))
+	files = append(files, script(installScript, install))
+
+	units := []unit{
+		{Name: "okr-install.service", Enabled: true, Contents: installUnit},
+		{Name: "okr.service", Enabled: true, Contents: okrUnit},
+		{Name: "okr-agent.service", Enabled: true, Contents: agentUnit},
+	}
+	if len(input.PostK3sCommands) > 0 {
+		post := append(append([]string{}, input.PostK3sCommands...), "touch "+postStamp)
+		files = append(files, script(postScript, post))
+		units = append(units, unit{Name: "okr-post.service", Enabled: true, Contents: postUnit})
+	}
+
+	result := config{
+		Ignition: ignition{Version: Version},
+		Systemd:  systemd{Units: units},
+	}
+	for _, f := range files {
+		converted, err := toFile(f)
+		if err != nil {
+			return nil, err
+		}
+		result.Storage.Files = append(result.Storage.Files, converted)
+	}
+
+	return json.Marshal(result)
+}
+
+func script(path string, commands []string) cloudinit.File {
+	return cloudinit.File{
+		Path:        path,
+		Content:     "#!/bin/sh\nset -e\n" + strings.Join(commands, "\n") + "\n",
+		Owner:       "root:root",
+		Permissions: "0700",
+	}
+}
+
+func toFile(f cloudinit.File) (file, error) {
+	result := file{
+		Path:      f.Path,
+		Overwrite: true,
+		Contents: contents{
+			Source: "data:;base64," + base64.StdEncoding.EncodeToString([]byte(f.Content)),
+		},
+	}
+
+	if f.Permissions != "" {
+		mode, err := strconv.ParseInt(f.Permissions, 8, 32)
+		if err != nil {
+			return result, fmt.Errorf("invalid permissions %s of %s: %w", f.Permissions, f.Path, err)
+		}
+		m := int(mode)
+		result.Mode = &m
+	}
+
+	if f.Owner != "" {
+		user, group, _ := strings.Cut(f.Owner, ":")
+		result.User = &owner{Name: user}
+		if group != "" {
+			result.Group = &owner{Name: group}
+		}
+	}
+	return result, nil
+}
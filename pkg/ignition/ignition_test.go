@@ -0,0 +1,166 @@
+package ignition
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/cluster-api/util/certs"
+	"sigs.k8s.io/cluster-api/util/secret"
+
+	"github.com/oneblock-ai/okr/pkg/cloudinit"
+	config2 "github.com/oneblock-ai/okr/pkg/k3s/config"
+)
+
+func baseUserData(post ...string) cloudinit.BaseUserData {
+	return cloudinit.BaseUserData{
+		PreK3sCommands:  []string{"echo pre"},
+		PostK3sCommands: post,
+		Config: config2.Config{
+			RuntimeConfig:     config2.RuntimeConfig{Role: "cluster-init", Token: "token"},
+			KubernetesVersion: "v1.28.4+k3s2",
+		},
+		OKRVersion: "v0.1.0",
+	}
+}
+
+func parse(t *testing.T, data []byte) (config, map[string]file) {
+	t.Helper()
+	result := config{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]file{}
+	for _, f := range result.Storage.Files {
+		files[f.Path] = f
+	}
+	return result, files
+}
+
+func content(t *testing.T, f file) string {
+	t.Helper()
+	encoded, ok := strings.CutPrefix(f.Contents.Source, "data:;base64,")
+	if !ok {
+		t.Fatalf("expected a base64 data URL for %s, got %s", f.Path, f.Contents.Source)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestNewInitControlPlane(t *testing.T) {
+	data, err := NewInitControlPlane(&cloudinit.ControlPlaneInput{
+		BaseUserData: baseUserData("echo post"),
+		Certificates: secret.Certificates{
+			&secret.Certificate{
+				Purpose: secret.ClusterCA,
+				KeyPair: &certs.KeyPair{Cert: []byte("ca-cert"), Key: []byte("ca-key")},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, files := parse(t, data)
+
+	if result.Ignition.Version != "3.3.0" {
+		t.Errorf("expected version 3.3.0, got %s", result.Ignition.Version)
+	}
+
+	modes := map[string]int{
+		cloudinit.ConfigFile: 0600,
+		installScript:        0700,
+		postScript:           0700,
+		"/var/lib/rancher/k3s/server/tls/server-ca.crt": 0640,
+		"/var/lib/rancher/k3s/server/tls/server-ca.key": 0600,
+		"/var/lib/rancher/k3s/server/tls/client-ca.crt": 0640,
+		"/var/lib/rancher/k3s/server/tls/client-ca.key": 0600,
+	}
+	if len(files) != len(modes) {
+		t.Errorf("expected %d files, got %d", len(modes), len(files))
+	}
+	for path, mode := range modes {
+		f, ok := files[path]
+		if !ok {
+			t.Errorf("expected the file %s", path)
+			continue
+		}
+		if f.Mode == nil || *f.Mode != mode {
+			t.Errorf("expected mode %o for %s, got %v", mode, path, f.Mode)
+		}
+		if !f.Overwrite || f.User == nil || f.User.Name != "root" || f.Group == nil || f.Group.Name != "root" {
+			t.Errorf("expected %s to be overwritten and owned by root:root, got %+v", path, f)
+		}
+	}
+
+	if cfg := content(t, files[cloudinit.ConfigFile]); !strings.Contains(cfg, "role: cluster-init") {
+		t.Errorf("expected the okr config, got\n%s", cfg)
+	}
+	if key := content(t, files["/var/lib/rancher/k3s/server/tls/server-ca.key"]); key != "ca-key" {
+		t.Errorf("expected the CA key, got %s", key)
+	}
+
+	install := strings.Split(strings.TrimSpace(content(t, files[installScript])), "\n")
+	expected := []string{
+		"#!/bin/sh",
+		"set -e",
+		"mkdir -p /opt/bin /run/okr-installer",
+		"echo pre",
+		"curl -sfL " + cloudinit.InstallScriptURL + " | INSTALL_OKR_SKIP_ENABLE=true INSTALL_OKR_BIN_DIR=/opt/bin INSTALL_OKR_SYSTEMD_DIR=/run/okr-installer INSTALL_OKR_VERSION=v0.1.0 sh -",
+	}
+	if !reflect.DeepEqual(install, expected) {
+		t.Errorf("expected the install script\n%v\ngot\n%v", expected, install)
+	}
+	if post := content(t, files[postScript]); !strings.Contains(post, "echo post\ntouch "+postStamp) {
+		t.Errorf("expected the post commands followed by the stamp, got\n%s", post)
+	}
+
+	var units []string
+	for _, u := range result.Systemd.Units {
+		if !u.Enabled {
+			t.Errorf("expected %s to be enabled", u.Name)
+		}
+		units = append(units, u.Name)
+	}
+	if expected := []string{"okr-install.service", "okr.service", "okr-agent.service", "okr-post.service"}; !reflect.DeepEqual(units, expected) {
+		t.Errorf("expected the units %v, got %v", expected, units)
+	}
+	if !strings.Contains(result.Systemd.Units[1].Contents, "ExecStart=/opt/bin/okr bootstrap") {
+		t.Errorf("expected okr.service to run okr from /opt/bin, got\n%s", result.Systemd.Units[1].Contents)
+	}
+	if !strings.Contains(result.Systemd.Units[2].Contents, "ExecStart=/opt/bin/okr agent") {
+		t.Errorf("expected okr-agent.service to run okr from /opt/bin, got\n%s", result.Systemd.Units[2].Contents)
+	}
+}
+
+func TestNewWorker(t *testing.T) {
+	data, err := NewWorker(&cloudinit.WorkerInput{BaseUserData: baseUserData()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, files := parse(t, data)
+
+	if _, ok := files[postScript]; ok {
+		t.Error("expected no post script without post commands")
+	}
+	for _, u := range result.Systemd.Units {
+		if u.Name == "okr-post.service" {
+			t.Error("expected no post unit without post commands")
+		}
+	}
+	if len(files) != 2 {
+		t.Errorf("expected the config and the install script, got %v", files)
+	}
+}
+
+func TestInvalidPermissions(t *testing.T) {
+	input := baseUserData()
+	input.AdditionalFiles = []cloudinit.File{{Path: "/etc/extra", Permissions: "rw"}}
+	if _, err := NewJoinControlPlane(&cloudinit.ControlPlaneInput{BaseUserData: input}); err == nil {
+		t.Error("expected an error for permissions that aren't octal")
+	}
+}
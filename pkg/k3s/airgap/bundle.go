@@ -0,0 +1,376 @@
+package airgap
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/images"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/resources"
+	runtime2 "github.com/oneblock-ai/okr/pkg/k3s/instructions/runtime"
+	"github.com/oneblock-ai/okr/pkg/k3s/versions"
+)
+
+const (
+	metadataFile = "bundle.json"
+	// installerDir holds the system agent installer images, they are looked up by the plan
+	// in the system agent images dir before pulling from a registry
+	installerDir = "installer"
+	// imagesDir holds the images imported by the runtime on start
+	imagesDir = "images"
+	// chartsDir holds the charts served by the runtime from its static charts dir
+	chartsDir = "charts"
+
+	systemAgentImagesDir = "/var/lib/rancher/agent/images"
+
+	k3sImagesURLFormat  = "https://github.com/k3s-io/k3s/releases/download/%s/k3s-airgap-images-%s.tar.zst"
+	rke2ImagesURLFormat = "https://github.com/rancher/rke2/releases/download/%s/rke2-images.linux-%s.tar.zst"
+)
+
+// Metadata describes what a bundle was created for
+type Metadata struct {
+	Runtime           config.Runtime `json:"runtime"`
+	KubernetesVersion string         `json:"kubernetesVersion"`
+	KubeRayVersion    string         `json:"kuberayVersion,omitempty"`
+	Arch              string         `json:"arch"`
+}
+
+type BundleConfig struct {
+	// Arch is the architecture of the nodes the bundle is for
+	Arch string
+	// Images are additional images to include, e.g. the images of the Ray clusters
+	Images []string
+	// Output is the path of the bundle to create
+	Output string
+}
+
+// Create downloads everything needed to install the runtime and KubeRay of cfg and packages it into one archive,
+// the partial archive is removed if that fails
+func Create(ctx context.Context, cfg *config.Config, bundle BundleConfig) (err error) {
+	k8sVersion, err := versions.K8sVersion(cfg.KubernetesVersion)
+	if err != nil {
+		return err
+	}
+	runtime := config.GetRuntime(k8sVersion)
+	if runtime == config.RuntimeUnknown {
+		return fmt.Errorf("unable to determine the runtime of kubernetes version %s", k8sVersion)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "okr-bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	metadata := Metadata{
+		Runtime:           runtime,
+		KubernetesVersion: k8sVersion,
+		Arch:              bundle.Arch,
+	}
+	if cfg.KubeRay.IsEnabled() {
+		metadata.KubeRayVersion = resources.KubeRayVersion(&cfg.KubeRay)
+	}
+
+	out, err := os.Create(bundle.Output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(bundle.Output)
+		}
+	}()
+
+	tw := tar.NewWriter(out)
+	metadataContent, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name: metadataFile,
+		Mode: 0644,
+		Size: int64(len(metadataContent)),
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(metadataContent); err != nil {
+		return err
+	}
+
+	add := func(dir, file string) error {
+		defer os.Remove(file)
+		return addFile(tw, path.Join(dir, filepath.Base(file)), file)
+	}
+
+	installerImage := images.GetInstallerImage(cfg.RuntimeInstallerImage, cfg.SystemDefaultRegistry, k8sVersion)
+	file, err := saveImage(ctx, tmpDir, installerImage, bundle.Arch)
+	if err != nil {
+		return err
+	}
+	if err := add(installerDir, file); err != nil {
+		return err
+	}
+
+	file, err = download(ctx, tmpDir, runtimeImagesURL(runtime, k8sVersion, bundle.Arch))
+	if err != nil {
+		return err
+	}
+	if err := add(imagesDir, file); err != nil {
+		return err
+	}
+
+	extraImages := bundle.Images
+	for _, chart := range resources.KubeRayCharts(&cfg.KubeRay) {
+		extraImages = append(extraImages, chart.Image)
+		file, err := downloadChart(ctx, tmpDir, &chart)
+		if err != nil {
+			return err
+		}
+		if file == "" {
+			continue
+		}
+		if err := add(chartsDir, file); err != nil {
+			return err
+		}
+	}
+
+	for _, image := range extraImages {
+		file, err := saveImage(ctx, tmpDir, image, bundle.Arch)
+		if err != nil {
+			return err
+		}
+		if err := add(imagesDir, file); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	logrus.Infof("Created bundle %s for %s %s", bundle.Output, runtime, k8sVersion)
+	return out.Close()
+}
+
+func runtimeImagesURL(runtime config.Runtime, k8sVersion, arch string) string {
+	// the + of the version must be escaped in the release download URL
+	version := strings.ReplaceAll(k8sVersion, "+", "%2B")
+	if runtime == config.RuntimeRKE2 {
+		return fmt.Sprintf(rke2ImagesURLFormat, version, arch)
+	}
+	return fmt.Sprintf(k3sImagesURLFormat, version, arch)
+}
+
+func saveImage(ctx context.Context, dir, image, arch string) (string, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", fmt.Errorf("parsing image %s: %w", image, err)
+	}
+
+	logrus.Infof("Pulling image %s", image)
+	img, err := remote.Image(ref,
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+		remote.WithPlatform(v1.Platform{OS: "linux", Architecture: arch}))
+	if err != nil {
+		return "", fmt.Errorf("pulling image %s: %w", image, err)
+	}
+
+	file := filepath.Join(dir, strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(image)+".tar")
+	if err := tarball.WriteToFile(file, ref, img); err != nil {
+		return "", fmt.Errorf("saving image %s: %w", image, err)
+	}
+	return file, nil
+}
+
+// downloadChart downloads the chart archive from the chart's helm repository, charts
+// configured as a URL are installed from that URL as is and not bundled
+func downloadChart(ctx context.Context, dir string, chart *resources.KubeRayChart) (string, error) {
+	if chart.Repo == "" {
+		logrus.Infof("Not bundling chart %s, it is not from a helm repository", chart.Chart)
+		return "", nil
+	}
+
+	index := filepath.Join(dir, "index.yaml")
+	if _, err := downloadTo(ctx, index, strings.TrimSuffix(chart.Repo, "/")+"/index.yaml"); err != nil {
+		return "", err
+	}
+	defer os.Remove(index)
+
+	chartURL, err := findChartURL(index, chart.Repo, chart.Chart, chart.Version)
+	if err != nil {
+		return "", err
+	}
+	return downloadTo(ctx, filepath.Join(dir, chart.StaticChartFile()), chartURL)
+}
+
+type repoIndex struct {
+	Entries map[string][]struct {
+		Version string   `json:"version"`
+		URLs    []string `json:"urls"`
+	} `json:"entries"`
+}
+
+func findChartURL(indexFile, repo, chart, version string) (string, error) {
+	data, err := os.ReadFile(indexFile)
+	if err != nil {
+		return "", err
+	}
+
+	index := repoIndex{}
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return "", fmt.Errorf("parsing index of helm repository %s: %w", repo, err)
+	}
+
+	for _, entry := range index.Entries[chart] {
+		if entry.Version != version || len(entry.URLs) == 0 {
+			continue
+		}
+		base, err := url.Parse(strings.TrimSuffix(repo, "/") + "/")
+		if err != nil {
+			return "", err
+		}
+		chartURL, err := base.Parse(entry.URLs[0])
+		if err != nil {
+			return "", err
+		}
+		return chartURL.String(), nil
+	}
+	return "", fmt.Errorf("chart %s version %s not found in helm repository %s", chart, version, repo)
+}
+
+func download(ctx context.Context, dir, fileURL string) (string, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return "", err
+	}
+	return downloadTo(ctx, filepath.Join(dir, path.Base(u.Path)), fileURL)
+}
+
+func downloadTo(ctx context.Context, file, fileURL string) (string, error) {
+	logrus.Infof("Downloading %s", fileURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("downloading %s: %w", fileURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("downloading %s: %s", fileURL, resp.Status)
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return "", fmt.Errorf("downloading %s: %w", fileURL, err)
+	}
+	return file, f.Close()
+}
+
+func addFile(tw *tar.Writer, name, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: info.Size(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// Extract places the contents of the bundle where the system agent and the runtime pick them up
+func Extract(bundle string) error {
+	f, err := os.Open(bundle)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	header, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("reading bundle %s: %w", bundle, err)
+	}
+	if header.Name != metadataFile {
+		return fmt.Errorf("%s is not an okr bundle, expected %s as the first entry", bundle, metadataFile)
+	}
+	metadata := Metadata{}
+	if err := json.NewDecoder(tr).Decode(&metadata); err != nil {
+		return fmt.Errorf("parsing %s of bundle %s: %w", metadataFile, bundle, err)
+	}
+
+	dirs := map[string]string{
+		installerDir: systemAgentImagesDir,
+		imagesDir:    filepath.Join(runtime2.GetDataDir(metadata.Runtime), "agent", "images"),
+		chartsDir:    filepath.Join(runtime2.GetDataDir(metadata.Runtime), "server", "static", "charts"),
+	}
+
+	logrus.Infof("Extracting bundle %s for %s %s", bundle, metadata.Runtime, metadata.KubernetesVersion)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading bundle %s: %w", bundle, err)
+		}
+
+		dir, ok := dirs[path.Dir(header.Name)]
+		if !ok || header.Typeflag != tar.TypeReg {
+			logrus.Warnf("Skipping unknown entry %s of bundle %s", header.Name, bundle)
+			continue
+		}
+		if err := extractFile(tr, filepath.Join(dir, path.Base(header.Name))); err != nil {
+			return err
+		}
+	}
+}
+
+func extractFile(r io.Reader, file string) error {
+	logrus.Infof("Writing %s", file)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("writing %s: %w", file, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,156 @@
+package airgap
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+)
+
+const testIndex = `entries:
+  kuberay-operator:
+  - version: 1.0.0
+    urls:
+    - kuberay-operator-1.0.0.tgz
+  - version: 1.1.0
+    urls:
+    - https://github.com/ray-project/kuberay-helm/releases/download/kuberay-operator-1.1.0/kuberay-operator-1.1.0.tgz
+`
+
+func TestFindChartURL(t *testing.T) {
+	index := filepath.Join(t.TempDir(), "index.yaml")
+	if err := os.WriteFile(index, []byte(testIndex), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		repo    string
+		version string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "relative url",
+			repo:    "https://ray-project.github.io/kuberay-helm",
+			version: "1.0.0",
+			want:    "https://ray-project.github.io/kuberay-helm/kuberay-operator-1.0.0.tgz",
+		},
+		{
+			name:    "relative url with trailing slash",
+			repo:    "https://ray-project.github.io/kuberay-helm/",
+			version: "1.0.0",
+			want:    "https://ray-project.github.io/kuberay-helm/kuberay-operator-1.0.0.tgz",
+		},
+		{
+			name:    "absolute url",
+			repo:    "https://ray-project.github.io/kuberay-helm",
+			version: "1.1.0",
+			want:    "https://github.com/ray-project/kuberay-helm/releases/download/kuberay-operator-1.1.0/kuberay-operator-1.1.0.tgz",
+		},
+		{
+			name:    "missing version",
+			repo:    "https://ray-project.github.io/kuberay-helm",
+			version: "2.0.0",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findChartURL(index, tt.repo, "kuberay-operator", tt.version)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	content  string
+}
+
+func writeBundle(t *testing.T, entries ...tarEntry) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "bundle.tar")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, entry := range entries {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Mode:     0644,
+			Size:     int64(len(entry.content)),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(entry.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestExtractRejectsMissingMetadata(t *testing.T) {
+	bundle := writeBundle(t,
+		tarEntry{name: "images/image.tar", typeflag: tar.TypeReg, content: "image"},
+		tarEntry{name: metadataFile, typeflag: tar.TypeReg, content: `{"runtime":"k3s"}`},
+	)
+	err := Extract(bundle)
+	if err == nil || !strings.Contains(err.Error(), "is not an okr bundle") {
+		t.Errorf("expected the bundle to be rejected, got %v", err)
+	}
+}
+
+func TestExtractSkipsUnknownEntries(t *testing.T) {
+	dir := t.TempDir()
+	bundle := writeBundle(t,
+		tarEntry{name: metadataFile, typeflag: tar.TypeReg, content: `{"runtime":"k3s","kubernetesVersion":"v1.28.4+k3s2"}`},
+		tarEntry{name: "unknown/file", typeflag: tar.TypeReg, content: "unknown"},
+		tarEntry{name: filepath.Join(dir, "file"), typeflag: tar.TypeReg, content: "absolute"},
+		tarEntry{name: "images/", typeflag: tar.TypeDir},
+		tarEntry{name: "images/link", typeflag: tar.TypeSymlink},
+	)
+	if err := Extract(bundle); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "file")); !os.IsNotExist(err) {
+		t.Errorf("expected the absolute entry to be skipped, got %v", err)
+	}
+}
+
+func TestCreateRemovesPartialBundle(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "bundle.tar")
+	cfg := &config.Config{
+		KubernetesVersion:     "v1.28.4+k3s2",
+		RuntimeInstallerImage: "invalid image",
+	}
+	if err := Create(context.Background(), cfg, BundleConfig{Arch: "amd64", Output: output}); err == nil {
+		t.Fatal("expected the invalid installer image to fail the bundle")
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("expected the partial bundle to be removed, got %v", err)
+	}
+}
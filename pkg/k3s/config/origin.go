@@ -0,0 +1,173 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/wrangler/v2/pkg/data"
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces the value of a secret in the output of okr
+const Redacted = "<redacted>"
+
+// secretKeys are the leaf keys whose values are redacted when showing the config
+var secretKeys = map[string]bool{
+	"token":         true,
+	"password":      true,
+	"auth":          true,
+	"identitytoken": true,
+	"identityToken": true,
+}
+
+// Origins records the files that contributed to every leaf key of the merged config
+type Origins map[string][]string
+
+// Of returns the files that set or appended to the leaf at path
+func (o Origins) Of(path ...string) []string {
+	return o[originKey(path)]
+}
+
+// LoadWithOrigins merges the config the same way Load does and records the origin of every leaf key
+func LoadWithOrigins(path string) (map[string]interface{}, Origins, error) {
+	sources, err := loadSources(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := map[string]interface{}{}
+	origins := Origins{}
+	for _, s := range sources {
+		origins.record(s.File, values, s.Values, nil, true)
+		values = data.MergeMapsConcatSlice(values, s.Values)
+	}
+	return values, origins, nil
+}
+
+// record mirrors data.MergeMapsConcatSlice, only top level slices are concatenated and
+// everything else that is not a map on both sides is replaced by the overlay
+func (o Origins) record(file string, base, overlay map[string]interface{}, path []string, topLevel bool) {
+	for k, v := range overlay {
+		keyPath := append(append([]string{}, path...), k)
+
+		baseMap, baseIsMap := base[k].(map[string]interface{})
+		overlayMap, overlayIsMap := v.(map[string]interface{})
+		if baseIsMap && overlayIsMap {
+			o.record(file, baseMap, overlayMap, keyPath, false)
+			continue
+		}
+
+		_, baseIsSlice := base[k].([]interface{})
+		_, overlayIsSlice := v.([]interface{})
+		if topLevel && baseIsSlice && overlayIsSlice {
+			o[originKey(keyPath)] = append(o[originKey(keyPath)], file)
+			continue
+		}
+
+		o.remove(keyPath)
+		o.set(file, v, keyPath)
+	}
+}
+
+func (o Origins) set(file string, value interface{}, path []string) {
+	if m, ok := value.(map[string]interface{}); ok && len(m) > 0 {
+		for k, v := range m {
+			o.set(file, v, append(append([]string{}, path...), k))
+		}
+		return
+	}
+	o[originKey(path)] = []string{file}
+}
+
+func (o Origins) remove(path []string) {
+	key := originKey(path)
+	for k := range o {
+		if k == key || strings.HasPrefix(k, key+"\x00") {
+			delete(o, k)
+		}
+	}
+}
+
+func originKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+// Redact returns a copy of values with the secret leaves replaced
+func Redact(values map[string]interface{}) map[string]interface{} {
+	return redact(values).(map[string]interface{})
+}
+
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			if _, isString := item.(string); isString && secretKeys[k] {
+				result[k] = Redacted
+				continue
+			}
+			result[k] = redact(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			result = append(result, redact(item))
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+// MarshalWithOrigins renders values as YAML with a comment listing the origin of every leaf key
+func MarshalWithOrigins(values map[string]interface{}, origins Origins) ([]byte, error) {
+	node, err := toNode(values, nil, origins)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func toNode(value interface{}, path []string, origins Origins) (*yaml.Node, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		node := &yaml.Node{}
+		if err := node.Encode(value); err != nil {
+			return nil, fmt.Errorf("encoding %s: %w", strings.Join(path, "."), err)
+		}
+		return node, nil
+	}
+
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, k := range sortedKeys(m) {
+		keyPath := append(append([]string{}, path...), k)
+		valueNode, err := toNode(m[k], keyPath, origins)
+		if err != nil {
+			return nil, err
+		}
+
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: k}
+		if files := origins.Of(keyPath...); len(files) > 0 {
+			comment := "from: " + strings.Join(files, ", ")
+			if valueNode.Kind == yaml.ScalarNode {
+				valueNode.LineComment = comment
+			} else {
+				keyNode.LineComment = comment
+			}
+		}
+		node.Content = append(node.Content, keyNode, valueNode)
+	}
+	return node, nil
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfigDir writes config.yaml and the files of its .d directory and returns the path
+// of config.yaml
+func writeConfigDir(t *testing.T, config string, dotD map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(path+".d", 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range dotD {
+		if err := os.WriteFile(filepath.Join(path+".d", name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestLoadWithOrigins(t *testing.T) {
+	path := writeConfigDir(t, `
+kubernetesVersion: v1.27.8+k3s2
+token: secret-token
+tlsSans:
+- a.example.com
+extraConfig:
+  write-kubeconfig-mode: "0644"
+  node-label:
+  - x=y
+registries:
+  configs:
+    registry.example.com:
+      auth:
+        username: user
+`, map[string]string{
+		"10-a.yaml": `
+kubernetesVersion: v1.28.4+k3s2
+tlsSans:
+- b.example.com
+registries:
+  configs:
+    registry.example.com:
+      auth:
+        password: secret-password
+`,
+		"20-b.yaml": `
+tlsSans:
+- c.example.com
+extraConfig: none
+`,
+	})
+	a, b := filepath.Join(path+".d", "10-a.yaml"), filepath.Join(path+".d", "20-b.yaml")
+
+	values, origins, err := LoadWithOrigins(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if values["kubernetesVersion"] != "v1.28.4+k3s2" {
+		t.Errorf("expected the version of the later file, got %v", values["kubernetesVersion"])
+	}
+	if sans := values["tlsSans"]; !reflect.DeepEqual(sans, []interface{}{"a.example.com", "b.example.com", "c.example.com"}) {
+		t.Errorf("expected the SANs of all files, got %v", sans)
+	}
+	if values["extraConfig"] != "none" {
+		t.Errorf("expected the map to be replaced by the scalar, got %v", values["extraConfig"])
+	}
+
+	tests := []struct {
+		path     []string
+		expected []string
+	}{
+		{path: []string{"kubernetesVersion"}, expected: []string{a}},
+		{path: []string{"token"}, expected: []string{path}},
+		{path: []string{"tlsSans"}, expected: []string{path, a, b}},
+		{path: []string{"extraConfig"}, expected: []string{b}},
+		{path: []string{"extraConfig", "write-kubeconfig-mode"}},
+		{path: []string{"extraConfig", "node-label"}},
+		{path: []string{"registries", "configs", "registry.example.com", "auth", "username"}, expected: []string{path}},
+		{path: []string{"registries", "configs", "registry.example.com", "auth", "password"}, expected: []string{a}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.path, "."), func(t *testing.T) {
+			if got := origins.Of(tt.path...); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected origins %v, got %v", tt.expected, got)
+			}
+		})
+	}
+	if len(origins) != 6 {
+		t.Errorf("expected only the origins of the merged leaves, got %v", origins)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	values := map[string]interface{}{
+		"token":    "secret-token",
+		"nodeName": "node1",
+		"registries": map[string]interface{}{
+			"configs": map[string]interface{}{
+				"registry.example.com": map[string]interface{}{
+					"auth": map[string]interface{}{
+						"username":      "user",
+						"password":      "secret-password",
+						"auth":          "c2VjcmV0",
+						"identityToken": "secret-identity",
+					},
+				},
+			},
+		},
+		"list": []interface{}{map[string]interface{}{"password": "secret-in-list"}},
+	}
+
+	redacted := Redact(values)
+
+	out, err := MarshalWithOrigins(redacted, Origins{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-token", "secret-password", "c2VjcmV0", "secret-identity", "secret-in-list"} {
+		if strings.Contains(string(out), secret) {
+			t.Errorf("expected %s to be redacted, got\n%s", secret, out)
+		}
+	}
+	if !strings.Contains(string(out), "user") || !strings.Contains(string(out), "node1") {
+		t.Errorf("expected the other values to be kept, got\n%s", out)
+	}
+	if values["token"] != "secret-token" {
+		t.Error("expected the values not to be modified")
+	}
+}
+
+func TestMarshalWithOrigins(t *testing.T) {
+	values := map[string]interface{}{
+		"kubernetesVersion": "v1.28.4+k3s2",
+		"tlsSans":           []interface{}{"a.example.com"},
+	}
+	origins := Origins{}
+	origins["kubernetesVersion"] = []string{"/etc/okr/config.yaml"}
+	origins["tlsSans"] = []string{"/etc/okr/config.yaml", "/etc/okr/config.yaml.d/10-a.yaml"}
+
+	out, err := MarshalWithOrigins(values, origins)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `kubernetesVersion: v1.28.4+k3s2 # from: /etc/okr/config.yaml
+tlsSans: # from: /etc/okr/config.yaml, /etc/okr/config.yaml.d/10-a.yaml
+  - a.example.com
+`
+	if string(out) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out)
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/rancher/system-agent/pkg/prober"
+)
+
+// Probe is a health check bootstrap waits for before it reports success, exactly one of
+// httpGet, tcpSocket or exec has to be set
+type Probe struct {
+	prober.Probe
+	TCPSocket *TCPSocketAction `json:"tcpSocket,omitempty"`
+	Exec      *ExecAction      `json:"exec,omitempty"`
+}
+
+// TCPSocketAction succeeds when a connection to Address can be established
+type TCPSocketAction struct {
+	// Address is the host:port to connect to
+	Address string `json:"address,omitempty"`
+}
+
+// ExecAction succeeds when Command exits with 0
+type ExecAction struct {
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	Env     []string `json:"env,omitempty"`
+}
+
+// IsHTTP is true for probes that can be run by the system agent prober
+func (p *Probe) IsHTTP() bool {
+	return p.TCPSocket == nil && p.Exec == nil
+}
+
+func (p *Probe) Validate() error {
+	actions := 0
+	if p.HTTPGetAction.URL != "" {
+		actions++
+		if _, err := url.ParseRequestURI(p.HTTPGetAction.URL); err != nil {
+			return fmt.Errorf("invalid httpGet url %q: %w", p.HTTPGetAction.URL, err)
+		}
+	}
+	if p.TCPSocket != nil {
+		actions++
+		if _, _, err := net.SplitHostPort(p.TCPSocket.Address); err != nil {
+			return fmt.Errorf("invalid tcpSocket address %q: %w", p.TCPSocket.Address, err)
+		}
+	}
+	if p.Exec != nil {
+		actions++
+		if p.Exec.Command == "" {
+			return fmt.Errorf("exec command is required")
+		}
+	}
+	if actions != 1 {
+		return fmt.Errorf("exactly one of httpGet, tcpSocket or exec is required")
+	}
+
+	if p.InitialDelaySeconds < 0 || p.TimeoutSeconds < 0 || p.SuccessThreshold < 0 || p.FailureThreshold < 0 {
+		return fmt.Errorf("initialDelaySeconds, timeoutSeconds, successThreshold and failureThreshold must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// RayCluster is a ray.io/v1 RayCluster created once KubeRay is deployed
+type RayCluster struct {
+	Name string `json:"name,omitempty"`
+	// Namespace defaults to default
+	Namespace string `json:"namespace,omitempty"`
+	// RayVersion defaults to 2.9.0
+	RayVersion string `json:"rayVersion,omitempty"`
+	// Image defaults to rayproject/ray:<rayVersion>
+	Image        string           `json:"image,omitempty"`
+	Head         RayHeadGroup     `json:"head,omitempty"`
+	WorkerGroups []RayWorkerGroup `json:"workerGroups,omitempty"`
+	// Autoscaling enables the in-tree autoscaler when set
+	Autoscaling *RayAutoscaling `json:"autoscaling,omitempty"`
+}
+
+type RayHeadGroup struct {
+	Resources      RayResources      `json:"resources,omitempty"`
+	RayStartParams map[string]string `json:"rayStartParams,omitempty"`
+	ServiceType    string            `json:"serviceType,omitempty"`
+}
+
+type RayWorkerGroup struct {
+	Name string `json:"name,omitempty"`
+	// Image overrides the image of the cluster for this group
+	Image          string            `json:"image,omitempty"`
+	Replicas       *int32            `json:"replicas,omitempty"`
+	MinReplicas    *int32            `json:"minReplicas,omitempty"`
+	MaxReplicas    *int32            `json:"maxReplicas,omitempty"`
+	Resources      RayResources      `json:"resources,omitempty"`
+	RayStartParams map[string]string `json:"rayStartParams,omitempty"`
+}
+
+// RayResources are used as both the requests and limits of a Ray container
+type RayResources struct {
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+	GPU    string `json:"gpu,omitempty"`
+}
+
+type RayAutoscaling struct {
+	// UpscalingMode is one of Default, Conservative or Aggressive
+	UpscalingMode      string `json:"upscalingMode,omitempty"`
+	IdleTimeoutSeconds *int32 `json:"idleTimeoutSeconds,omitempty"`
+}
+
+var upscalingModes = []string{"Default", "Conservative", "Aggressive"}
+
+// ValidateRayClusters checks the shape of the ray clusters before they are rendered
+func ValidateRayClusters(clusters []RayCluster) error {
+	seen := map[string]bool{}
+	for i, cluster := range clusters {
+		if err := cluster.Validate(); err != nil {
+			return fmt.Errorf("rayClusters[%d]: %w", i, err)
+		}
+		key := cluster.Namespace + "/" + cluster.Name
+		if seen[key] {
+			return fmt.Errorf("rayClusters[%d]: duplicate ray cluster %s", i, cluster.Name)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+func (r *RayCluster) Validate() error {
+	if errs := validation.IsDNS1123Label(r.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid name %q: %s", r.Name, strings.Join(errs, ", "))
+	}
+	if r.Namespace != "" {
+		if errs := validation.IsDNS1123Label(r.Namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace %q: %s", r.Namespace, strings.Join(errs, ", "))
+		}
+	}
+	if err := r.Head.Resources.Validate(); err != nil {
+		return fmt.Errorf("head: %w", err)
+	}
+
+	groups := map[string]bool{}
+	for i, group := range r.WorkerGroups {
+		if err := group.Validate(); err != nil {
+			return fmt.Errorf("workerGroups[%d]: %w", i, err)
+		}
+		if groups[group.Name] {
+			return fmt.Errorf("workerGroups[%d]: duplicate worker group %s", i, group.Name)
+		}
+		groups[group.Name] = true
+	}
+
+	if r.Autoscaling != nil && r.Autoscaling.UpscalingMode != "" {
+		valid := false
+		for _, mode := range upscalingModes {
+			valid = valid || mode == r.Autoscaling.UpscalingMode
+		}
+		if !valid {
+			return fmt.Errorf("invalid autoscaling upscalingMode %q, must be one of %s",
+				r.Autoscaling.UpscalingMode, strings.Join(upscalingModes, ", "))
+		}
+	}
+	return nil
+}
+
+func (g *RayWorkerGroup) Validate() error {
+	if errs := validation.IsDNS1123Label(g.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid name %q: %s", g.Name, strings.Join(errs, ", "))
+	}
+	for name, value := range map[string]*int32{
+		"replicas":    g.Replicas,
+		"minReplicas": g.MinReplicas,
+		"maxReplicas": g.MaxReplicas,
+	} {
+		if value != nil && *value < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	if g.MinReplicas != nil && g.MaxReplicas != nil && *g.MinReplicas > *g.MaxReplicas {
+		return fmt.Errorf("minReplicas %d is greater than maxReplicas %d", *g.MinReplicas, *g.MaxReplicas)
+	}
+	if g.Replicas != nil {
+		if g.MinReplicas != nil && *g.Replicas < *g.MinReplicas {
+			return fmt.Errorf("replicas %d is less than minReplicas %d", *g.Replicas, *g.MinReplicas)
+		}
+		if g.MaxReplicas != nil && *g.Replicas > *g.MaxReplicas {
+			return fmt.Errorf("replicas %d is greater than maxReplicas %d", *g.Replicas, *g.MaxReplicas)
+		}
+	}
+	return g.Resources.Validate()
+}
+
+func (r *RayResources) Validate() error {
+	for name, value := range map[string]string{
+		"cpu":    r.CPU,
+		"memory": r.Memory,
+		"gpu":    r.GPU,
+	} {
+		if value == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(value); err != nil {
+			return fmt.Errorf("invalid %s quantity %q: %w", name, value, err)
+		}
+	}
+	return nil
+}
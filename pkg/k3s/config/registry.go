@@ -0,0 +1,220 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Registry is the registries.yaml of k3s and RKE2, the json tags are the keys in the okr
+// config and the yaml tags the keys in the rendered registries.yaml
+type Registry struct {
+	// Mirrors are namespace to mirror mapping for all namespaces.
+	Mirrors map[string]Mirror `json:"mirrors,omitempty" yaml:"mirrors,omitempty"`
+	// Configs are configs for each registry.
+	// The key is the FDQN or IP of the registry.
+	Configs map[string]RegistryConfig `json:"configs,omitempty" yaml:"configs,omitempty"`
+
+	// Auths are registry endpoint to auth config mapping. The registry endpoint must
+	// be a valid url with host specified.
+	// DEPRECATED: Use Configs instead. Remove in containerd 1.4.
+	Auths map[string]AuthConfig `json:"auths,omitempty" yaml:"auths,omitempty"`
+}
+
+// Mirror contains the config related to the registry mirror
+type Mirror struct {
+	// Endpoints are endpoints for a namespace. CRI plugin will try the endpoints
+	// one by one until a working one is found. The endpoint must be a valid url
+	// with host specified.
+	// The scheme, host and path from the endpoint URL will be used.
+	Endpoints []string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+
+	// Rewrites are repository rewrite rules for a namespace. When fetching image resources
+	// from an endpoint and a key matches the repository via regular expression matching
+	// it will be replaced with the corresponding value from the map in the resource request.
+	Rewrites map[string]string `json:"rewrite,omitempty" yaml:"rewrite,omitempty"`
+}
+
+// RegistryConfig contains configuration used to communicate with the registry.
+type RegistryConfig struct {
+	// Auth contains information to authenticate to the registry.
+	Auth *AuthConfig `json:"auth,omitempty" yaml:"auth,omitempty"`
+	// TLS is a pair of CA/Cert/Key which then are used when creating the transport
+	// that communicates with the registry.
+	TLS *TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty"`
+}
+
+// AuthConfig contains the config related to authentication to a specific registry. The
+// password and identity token can be read from a file or an environment variable of okr
+// instead of being set inline.
+type AuthConfig struct {
+	// Username is the username to login the registry.
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	// Password is the password to login the registry.
+	Password     string `json:"password,omitempty" yaml:"password,omitempty"`
+	PasswordFile string `json:"passwordFile,omitempty" yaml:"-"`
+	PasswordEnv  string `json:"passwordEnv,omitempty" yaml:"-"`
+	// Auth is a base64 encoded string from the concatenation of the username,
+	// a colon, and the password.
+	Auth string `json:"auth,omitempty" yaml:"auth,omitempty"`
+	// IdentityToken is used to authenticate the user and get
+	// an access token for the registry.
+	IdentityToken     string `json:"identitytoken,omitempty" yaml:"identity_token,omitempty"`
+	IdentityTokenFile string `json:"identityTokenFile,omitempty" yaml:"-"`
+	IdentityTokenEnv  string `json:"identityTokenEnv,omitempty" yaml:"-"`
+}
+
+// TLSConfig contains the CA/Cert/Key used for a registry
+type TLSConfig struct {
+	CAFile             string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+	CertFile           string `json:"cert_file,omitempty" yaml:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+// Resolve returns a copy of the auth config with the password and identity token read
+// from their file or environment variable
+func (a AuthConfig) Resolve() (AuthConfig, error) {
+	password, err := credential("password", a.Password, a.PasswordFile, a.PasswordEnv)
+	if err != nil {
+		return a, err
+	}
+	identityToken, err := credential("identityToken", a.IdentityToken, a.IdentityTokenFile, a.IdentityTokenEnv)
+	if err != nil {
+		return a, err
+	}
+
+	return AuthConfig{
+		Username:      a.Username,
+		Password:      password,
+		Auth:          a.Auth,
+		IdentityToken: identityToken,
+	}, nil
+}
+
+func credential(name, inline, file, env string) (string, error) {
+	set := 0
+	for _, source := range []string{inline, file, env} {
+		if source != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return "", fmt.Errorf("only one of %s, %sFile and %sEnv can be set", name, name, name)
+	}
+
+	switch {
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("reading %s: %w", name, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case env != "":
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			return "", fmt.Errorf("reading %s: environment variable %s is not set", name, env)
+		}
+		return value, nil
+	}
+	return inline, nil
+}
+
+// Resolve returns a copy of the registry with the credentials of every auth config read
+func (r *Registry) Resolve() (*Registry, error) {
+	result := &Registry{
+		Mirrors: r.Mirrors,
+	}
+
+	for name, config := range r.Configs {
+		if config.Auth != nil {
+			auth, err := config.Auth.Resolve()
+			if err != nil {
+				return nil, fmt.Errorf("configs %s: %w", name, err)
+			}
+			config.Auth = &auth
+		}
+		if result.Configs == nil {
+			result.Configs = map[string]RegistryConfig{}
+		}
+		result.Configs[name] = config
+	}
+
+	for name, auth := range r.Auths {
+		resolved, err := auth.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("auths %s: %w", name, err)
+		}
+		if result.Auths == nil {
+			result.Auths = map[string]AuthConfig{}
+		}
+		result.Auths[name] = resolved
+	}
+
+	return result, nil
+}
+
+// Validate checks the mirror endpoints, the TLS files and the credentials of the registry
+func (r *Registry) Validate() (errs []error) {
+	for _, name := range sortedKeys(r.Mirrors) {
+		for _, endpoint := range r.Mirrors[name].Endpoints {
+			if err := validateEndpoint(endpoint); err != nil {
+				errs = append(errs, fmt.Errorf("mirrors %s: %w", name, err))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(r.Configs) {
+		config := r.Configs[name]
+		if config.TLS != nil {
+			for _, err := range config.TLS.validate() {
+				errs = append(errs, fmt.Errorf("configs %s: %w", name, err))
+			}
+		}
+		if config.Auth != nil {
+			if _, err := config.Auth.Resolve(); err != nil {
+				errs = append(errs, fmt.Errorf("configs %s: %w", name, err))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(r.Auths) {
+		auth := r.Auths[name]
+		if _, err := auth.Resolve(); err != nil {
+			errs = append(errs, fmt.Errorf("auths %s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+func (t *TLSConfig) validate() (errs []error) {
+	for _, file := range []string{t.CAFile, t.CertFile, t.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		errs = append(errs, errors.New("cert_file and key_file must be set together"))
+	}
+	return errs
+}
+
+// validateEndpoint checks that the endpoint is an http or https URL with a host
+func validateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid endpoint %q, the scheme must be http or https", endpoint)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q, a host is required", endpoint)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCredential(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("OKR_TEST_PASSWORD", "from-env")
+
+	tests := []struct {
+		name     string
+		inline   string
+		file     string
+		env      string
+		expected string
+		err      string
+	}{
+		{name: "unset"},
+		{name: "inline", inline: "inline", expected: "inline"},
+		{name: "file", file: file, expected: "from-file"},
+		{name: "env", env: "OKR_TEST_PASSWORD", expected: "from-env"},
+		{name: "missing file", file: filepath.Join(t.TempDir(), "missing"), err: "reading password"},
+		{name: "missing env", env: "OKR_TEST_MISSING", err: "OKR_TEST_MISSING is not set"},
+		{name: "inline and file", inline: "inline", file: file, err: "only one of"},
+		{name: "file and env", file: file, env: "OKR_TEST_PASSWORD", err: "only one of"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := credential("password", tt.inline, tt.file, tt.env)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("expected an error about %s, got %v", tt.err, err)
+			case value != tt.expected:
+				t.Errorf("expected %q, got %q", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		err      string
+	}{
+		{endpoint: "https://mirror.example.com"},
+		{endpoint: "http://10.0.0.1:5000/v2"},
+		{endpoint: "mirror.example.com", err: "scheme"},
+		{endpoint: "ftp://mirror.example.com", err: "scheme"},
+		{endpoint: "https://", err: "host"},
+		{endpoint: "https://mirror example.com", err: "invalid endpoint"},
+	}
+	for _, tt := range tests {
+		err := validateEndpoint(tt.endpoint)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: expected no error, got %v", tt.endpoint, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: expected an error about %s, got %v", tt.endpoint, tt.err, err)
+		}
+	}
+}
+
+func TestRegistryValidate(t *testing.T) {
+	dir := t.TempDir()
+	ca := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(ca, []byte("ca"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		registry Registry
+		errs     []string
+	}{
+		{name: "empty"},
+		{
+			name: "valid",
+			registry: Registry{
+				Mirrors: map[string]Mirror{"docker.io": {Endpoints: []string{"https://mirror.example.com"}}},
+				Configs: map[string]RegistryConfig{"mirror.example.com": {
+					Auth: &AuthConfig{Username: "okr", Password: "secret"},
+					TLS:  &TLSConfig{CAFile: ca},
+				}},
+			},
+		},
+		{
+			name: "invalid mirror endpoints",
+			registry: Registry{
+				Mirrors: map[string]Mirror{
+					"quay.io":   {Endpoints: []string{"quay.example.com"}},
+					"docker.io": {Endpoints: []string{"https://mirror.example.com", "https://"}},
+				},
+			},
+			errs: []string{"mirrors docker.io: invalid endpoint", "mirrors quay.io: invalid endpoint"},
+		},
+		{
+			name: "invalid tls",
+			registry: Registry{
+				Configs: map[string]RegistryConfig{"mirror.example.com": {
+					TLS: &TLSConfig{CAFile: filepath.Join(dir, "missing.crt"), CertFile: ca},
+				}},
+			},
+			errs: []string{"configs mirror.example.com: stat", "configs mirror.example.com: cert_file and key_file"},
+		},
+		{
+			name: "invalid credentials",
+			registry: Registry{
+				Configs: map[string]RegistryConfig{"mirror.example.com": {
+					Auth: &AuthConfig{PasswordEnv: "OKR_TEST_MISSING"},
+				}},
+				Auths: map[string]AuthConfig{"registry.example.com": {
+					IdentityToken: "token", IdentityTokenFile: ca,
+				}},
+			},
+			errs: []string{"configs mirror.example.com: reading password", "auths registry.example.com: only one of identityToken"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.registry.Validate()
+			if len(errs) != len(tt.errs) {
+				t.Fatalf("expected %d errors, got %v", len(tt.errs), errs)
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), tt.errs[i]) {
+					t.Errorf("expected an error about %s, got %v", tt.errs[i], err)
+				}
+			}
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package config
 
-import "strings"
+import (
+	"fmt"
+	"os"
+	"strings"
+)
 
 var (
 	RuntimeK3S     Runtime = "k3s"
+	RuntimeRKE2    Runtime = "rke2"
 	RuntimeUnknown Runtime = "unknown"
 )
 
@@ -26,5 +31,20 @@ func GetRuntime(kubernetesVersion string) Runtime {
 	if strings.Contains(kubernetesVersion, "k3s") {
 		return RuntimeK3S
 	}
+	if strings.Contains(kubernetesVersion, "rke2") {
+		return RuntimeRKE2
+	}
 	return RuntimeUnknown
 }
+
+// GetNodeName returns the configured node name, falling back to the short hostname
+func GetNodeName(cfg *RuntimeConfig) (string, error) {
+	if cfg.NodeName != "" {
+		return cfg.NodeName, nil
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("looking up hostname: %w", err)
+	}
+	return strings.Split(hostname, ".")[0], nil
+}
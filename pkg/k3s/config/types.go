@@ -1,18 +1,21 @@
 package config
 
 import (
+	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/rancher/system-agent/pkg/applyinator"
-	"github.com/rancher/wharfie/pkg/registries"
 	"github.com/rancher/wrangler/v2/pkg/data"
 	"github.com/rancher/wrangler/v2/pkg/data/convert"
 	"github.com/rancher/wrangler/v2/pkg/yaml"
 	"github.com/sirupsen/logrus"
 
+	"github.com/oneblock-ai/okr/pkg/k3s/retry"
+	"github.com/oneblock-ai/okr/pkg/k3s/versions"
 	"github.com/oneblock-ai/okr/pkg/utils"
 )
 
@@ -33,15 +36,177 @@ var (
 
 type Config struct {
 	RuntimeConfig
-	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+	KubernetesVersion string   `json:"kubernetesVersion,omitempty"`
+	Channels          Channels `json:"channels,omitempty"`
 
 	PreOneTimeInstructions  []applyinator.OneTimeInstruction `json:"preInstructions,omitempty"`
 	PostOneTimeInstructions []applyinator.OneTimeInstruction `json:"postInstructions,omitempty"`
 	Resources               []utils.GenericMap               `json:"resources,omitempty"`
+	// PeriodicInstructions are run on bootstrap and then every periodSeconds by okr agent
+	PeriodicInstructions []applyinator.PeriodicInstruction `json:"periodicInstructions,omitempty"`
 
-	RuntimeInstallerImage string               `json:"runtimeInstallerImage,omitempty"`
-	SystemDefaultRegistry string               `json:"systemDefaultRegistry,omitempty"`
-	Registries            *registries.Registry `json:"registries,omitempty"`
+	RuntimeInstallerImage string    `json:"runtimeInstallerImage,omitempty"`
+	SystemDefaultRegistry string    `json:"systemDefaultRegistry,omitempty"`
+	Registries            *Registry `json:"registries,omitempty"`
+
+	KubeRay     KubeRay      `json:"kuberay,omitempty"`
+	RayClusters []RayCluster `json:"rayClusters,omitempty"`
+	// KubeRayVersion is the deprecated form of kuberay.version, it is still read from the
+	// configs and done stamps of older releases
+	KubeRayVersion string `json:"kubeRayVersion,omitempty"`
+
+	// Probes are checked in addition to the built-in probes of the node's role
+	Probes map[string]Probe `json:"probes,omitempty"`
+
+	Airgap Airgap `json:"airgap,omitempty"`
+
+	RetryPolicy RetryPolicy `json:"retryPolicy,omitempty"`
+}
+
+// RetryPolicy configures how bootstrap and the commands of the plan are retried, unset
+// fields use the defaults of the retry package
+type RetryPolicy struct {
+	Backoff
+	// InstructionAttempts is how often the system-agent runs a failing instruction of the plan, defaults to 5
+	InstructionAttempts int `json:"instructionAttempts,omitempty"`
+	// Resources is how the kubectl apply of the bootstrap resources is retried, it is retried
+	// forever with the default backoff if unset
+	Resources Backoff `json:"resources,omitempty"`
+}
+
+// Backoff is an exponential backoff with jitter, an explicit zero multiplier or jitter is kept
+type Backoff struct {
+	// InitialInterval is the wait after the first failed attempt
+	InitialInterval string `json:"initialInterval,omitempty"`
+	// MaxInterval caps the wait between two attempts
+	MaxInterval string   `json:"maxInterval,omitempty"`
+	Multiplier  *float64 `json:"multiplier,omitempty"`
+	// Jitter of 0 disables the randomization of the waits
+	Jitter *float64 `json:"jitter,omitempty"`
+	// MaxAttempts is the number of attempts before giving up, retries forever if unset
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// AttemptTimeout bounds a single attempt
+	AttemptTimeout string `json:"attemptTimeout,omitempty"`
+	// Timeout bounds all attempts including the waits between them
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// Policy returns the backoff of bootstrap described by the config
+func (r *RetryPolicy) Policy() (retry.Policy, error) {
+	if r.InstructionAttempts < 0 {
+		return retry.Policy{}, fmt.Errorf("retryPolicy instructionAttempts must not be negative")
+	}
+	return r.Backoff.policy()
+}
+
+// ResourcesPolicy returns the backoff of the kubectl apply of the bootstrap resources
+func (r *RetryPolicy) ResourcesPolicy() (retry.Policy, error) {
+	policy, err := r.Resources.policy()
+	if err != nil {
+		return policy, fmt.Errorf("retryPolicy resources: %w", err)
+	}
+	return policy, nil
+}
+
+func (b *Backoff) policy() (retry.Policy, error) {
+	policy := retry.Policy{
+		Multiplier:  b.Multiplier,
+		Jitter:      b.Jitter,
+		MaxAttempts: b.MaxAttempts,
+	}
+	durations := []struct {
+		name  string
+		value string
+		to    *time.Duration
+	}{
+		{"initialInterval", b.InitialInterval, &policy.InitialInterval},
+		{"maxInterval", b.MaxInterval, &policy.MaxInterval},
+		{"attemptTimeout", b.AttemptTimeout, &policy.AttemptTimeout},
+		{"timeout", b.Timeout, &policy.Timeout},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return policy, fmt.Errorf("parsing %s %s: %w", d.name, d.value, err)
+		}
+		*d.to = parsed
+	}
+	return policy, policy.Validate()
+}
+
+// Airgap installs the runtime and KubeRay from a bundle created by okr bundle instead
+// of pulling them from the internet
+type Airgap struct {
+	// Bundle is the path of the bundle on the node
+	Bundle string `json:"bundle,omitempty"`
+}
+
+func (a *Airgap) IsEnabled() bool {
+	return a.Bundle != ""
+}
+
+// Channels configures how a channel name in the kubernetesVersion is resolved
+type Channels struct {
+	// Server replaces the k3s or RKE2 channel server, the channel name is appended to it
+	Server string `json:"server,omitempty"`
+	// File is a local copy of the channels served by the channel server for air-gapped sites
+	File string `json:"file,omitempty"`
+	// CacheTTL is how long a resolved channel is cached in the data dir, defaults to 24h
+	CacheTTL string `json:"cacheTTL,omitempty"`
+}
+
+// VersionOptions returns the options to resolve channels with, caching them below dataDir
+func (c *Channels) VersionOptions(dataDir string) (versions.Options, error) {
+	opts := versions.Options{
+		ChannelServer: c.Server,
+		ChannelsFile:  c.File,
+		CacheDir:      filepath.Join(dataDir, "channels"),
+	}
+	if c.CacheTTL != "" {
+		ttl, err := time.ParseDuration(c.CacheTTL)
+		if err != nil {
+			return opts, fmt.Errorf("parsing channels cacheTTL %s: %w", c.CacheTTL, err)
+		}
+		opts.CacheTTL = ttl
+	}
+	return opts, nil
+}
+
+// KubeRay configures the HelmCharts used to deploy KubeRay on the cluster-init node
+type KubeRay struct {
+	// Enabled deploys the KubeRay operator, defaults to true
+	Enabled *bool `json:"enabled,omitempty"`
+	HelmChart
+	// APIServer deploys the optional KubeRay apiserver chart when set
+	APIServer *HelmChart `json:"apiserver,omitempty"`
+}
+
+type HelmChart struct {
+	Version string `json:"version,omitempty"`
+	// Repo is the helm repository, it is ignored when Chart is a URL to a chart archive
+	Repo            string `json:"repo,omitempty"`
+	Chart           string `json:"chart,omitempty"`
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	ValuesContent   string `json:"valuesContent,omitempty"`
+}
+
+func (k *KubeRay) IsEnabled() bool {
+	return k.Enabled == nil || *k.Enabled
+}
+
+// MigrateDeprecated moves the values of deprecated keys to the keys replacing them, a value
+// of the replacing key takes precedence
+func (c *Config) MigrateDeprecated() {
+	if c.KubeRayVersion == "" {
+		return
+	}
+	if c.KubeRay.Version == "" {
+		c.KubeRay.Version = c.KubeRayVersion
+	}
+	c.KubeRayVersion = ""
 }
 
 func paths() (result []string) {
@@ -63,34 +228,63 @@ func paths() (result []string) {
 }
 
 func Load(path string) (result Config, err error) {
-	var values = map[string]interface{}{}
-
 	if err := populatedSystemResources(&result); err != nil {
 		return result, err
 	}
 
+	sources, err := loadSources(path)
+	if err != nil {
+		return result, err
+	}
+
+	err = convert.ToObj(merge(sources), &result)
+	if err != nil {
+		return
+	}
+	if result.KubeRayVersion != "" {
+		logrus.Warnf("kubeRayVersion is deprecated, use kuberay.version instead")
+	}
+	result.MigrateDeprecated()
+
+	return result, err
+}
+
+// source is the okr config read from a single file
+type source struct {
+	File   string
+	Values map[string]interface{}
+	// CloudConfig is set for cloud-config user data without an okr key, whose keys belong to cloud-init
+	CloudConfig bool
+}
+
+// loadSources returns the config of every implicit and explicit file in the order they are merged
+func loadSources(path string) (result []source, _ error) {
 	for _, file := range paths() {
-		newValues, err := mergeFile(values, file)
+		sources, err := readSources(file)
 		if err == nil {
-			values = newValues
+			result = append(result, sources...)
 		} else {
 			logrus.Warnf("failed to parse %s, skipping file: %v", file, err)
 		}
 	}
 
 	if path != "" {
-		values, err = mergeFile(values, path)
+		sources, err := readSources(path)
 		if err != nil {
-			return
+			return nil, err
 		}
+		result = append(result, sources...)
 	}
 
-	err = convert.ToObj(values, &result)
-	if err != nil {
-		return
-	}
+	return result, nil
+}
 
-	return result, err
+func merge(sources []source) map[string]interface{} {
+	var values = map[string]interface{}{}
+	for _, s := range sources {
+		values = data.MergeMapsConcatSlice(values, s.Values)
+	}
+	return values
 }
 
 func populatedSystemResources(config *Config) error {
@@ -153,7 +347,7 @@ func loadResources(dirs ...string) (result []utils.GenericMap, _ error) {
 	return
 }
 
-func mergeFile(result map[string]interface{}, file string) (map[string]interface{}, error) {
+func readSources(file string) ([]source, error) {
 	bytes, err := os.ReadFile(file)
 	if err != nil && !os.IsNotExist(err) {
 		return nil, err
@@ -172,16 +366,23 @@ func mergeFile(result map[string]interface{}, file string) (map[string]interface
 		}
 	}
 
+	cloudConfig := strings.HasPrefix(string(bytes), "#cloud-config")
 	if v, ok := values["okr"].(map[string]interface{}); ok {
 		values = v
+		cloudConfig = false
 	}
 
-	result = data.MergeMapsConcatSlice(result, values)
+	result := []source{{
+		File:        file,
+		Values:      values,
+		CloudConfig: cloudConfig,
+	}}
 	for _, file := range files {
-		result, err = mergeFile(result, file)
+		sources, err := readSources(file)
 		if err != nil {
 			return nil, err
 		}
+		result = append(result, sources...)
 	}
 
 	return result, nil
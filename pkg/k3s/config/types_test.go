@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMigratesKubeRayVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected string
+	}{
+		{
+			name:     "deprecated key",
+			config:   "kubeRayVersion: 1.0.0\n",
+			expected: "1.0.0",
+		},
+		{
+			name:     "replacing key takes precedence",
+			config:   "kubeRayVersion: 1.0.0\nkuberay:\n  version: 1.1.0\n",
+			expected: "1.1.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.KubeRay.Version != tt.expected {
+				t.Errorf("expected kuberay.version %s, got %s", tt.expected, cfg.KubeRay.Version)
+			}
+			if cfg.KubeRayVersion != "" {
+				t.Errorf("expected kubeRayVersion to be cleared, got %s", cfg.KubeRayVersion)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyKeepsExplicitZero(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("retryPolicy:\n  jitter: 0\n  resources:\n    multiplier: 0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := cfg.RetryPolicy.Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Jitter == nil || *policy.Jitter != 0 {
+		t.Errorf("expected jitter 0 to be kept, got %v", policy.Jitter)
+	}
+	if policy.Multiplier != nil {
+		t.Errorf("expected the default multiplier, got %v", *policy.Multiplier)
+	}
+	if _, err := cfg.RetryPolicy.ResourcesPolicy(); err == nil {
+		t.Error("expected multiplier 0 of the resources to be invalid")
+	}
+}
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/rancher/wrangler/v2/pkg/data/convert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/roles"
+	"github.com/oneblock-ai/okr/pkg/k3s/versions"
+)
+
+var (
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	taintEffects    = []string{
+		string(corev1.TaintEffectNoSchedule),
+		string(corev1.TaintEffectPreferNoSchedule),
+		string(corev1.TaintEffectNoExecute),
+	}
+)
+
+// Issue is a problem found in the config, File is the file that set the offending key if known
+type Issue struct {
+	File    string
+	Key     string
+	Message string
+}
+
+func (i Issue) String() string {
+	file := i.File
+	if file == "" {
+		file = "<merged config>"
+	}
+	return fmt.Sprintf("%s: %s: %s", file, i.Key, i.Message)
+}
+
+// Validate loads the config the same way Load does and reports unknown keys and invalid values
+func Validate(path string) ([]Issue, error) {
+	sources, err := loadSources(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+	for _, s := range sources {
+		if s.CloudConfig {
+			continue
+		}
+		for _, key := range unknownKeys(reflect.TypeOf(Config{}), s.Values, "") {
+			issues = append(issues, Issue{
+				File:    s.File,
+				Key:     key,
+				Message: "unknown key",
+			})
+		}
+	}
+
+	cfg := Config{}
+	if err := convert.ToObj(merge(sources), &cfg); err != nil {
+		return nil, err
+	}
+
+	addIssue := func(key, format string, args ...interface{}) {
+		issues = append(issues, Issue{
+			File:    origin(sources, key),
+			Key:     key,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if cfg.Role != "" {
+		role, err := roles.Parse(cfg.Role)
+		if err != nil {
+			addIssue("role", "%v", err)
+		} else if !role.ClusterInit {
+			if cfg.Server == "" {
+				addIssue("server", "server is required for all roles besides cluster-init")
+			}
+			if cfg.Token == "" {
+				addIssue("token", "token is required for all roles besides cluster-init")
+			}
+		}
+	}
+
+	if err := versions.Validate(cfg.KubernetesVersion); err != nil {
+		addIssue("kubernetesVersion", "%v", err)
+	}
+
+	if cfg.Channels.Server != "" {
+		if _, err := url.ParseRequestURI(cfg.Channels.Server); err != nil {
+			addIssue("channels", "invalid server %q: %v", cfg.Channels.Server, err)
+		}
+	}
+	if cfg.Channels.File != "" {
+		if _, err := os.Stat(cfg.Channels.File); err != nil {
+			addIssue("channels", "invalid file: %v", err)
+		}
+	}
+	if _, err := cfg.Channels.VersionOptions(""); err != nil {
+		addIssue("channels", "%v", err)
+	}
+
+	periodicNames := map[string]bool{}
+	for i, instruction := range cfg.PeriodicInstructions {
+		switch {
+		case instruction.Name == "":
+			addIssue("periodicInstructions", "instruction %d: name is required", i)
+		case periodicNames[instruction.Name]:
+			addIssue("periodicInstructions", "instruction %d: duplicate name %s", i, instruction.Name)
+		}
+		periodicNames[instruction.Name] = true
+		if instruction.Command == "" && instruction.Image == "" {
+			addIssue("periodicInstructions", "instruction %d: command or image is required", i)
+		}
+		if instruction.PeriodSeconds < 0 {
+			addIssue("periodicInstructions", "instruction %d: periodSeconds must not be negative", i)
+		}
+	}
+
+	if _, err := cfg.RetryPolicy.Policy(); err != nil {
+		addIssue("retryPolicy", "%v", err)
+	}
+	if _, err := cfg.RetryPolicy.ResourcesPolicy(); err != nil {
+		addIssue("retryPolicy", "%v", err)
+	}
+
+	if cfg.Registries != nil {
+		for _, err := range cfg.Registries.Validate() {
+			addIssue("registries", "%v", err)
+		}
+	}
+
+	for _, taint := range cfg.Taints {
+		if err := validateTaint(taint); err != nil {
+			addIssue("taints", "%v", err)
+		}
+	}
+
+	if err := ValidateRayClusters(cfg.RayClusters); err != nil {
+		addIssue("rayClusters", "%v", err)
+	}
+
+	probeNames := make([]string, 0, len(cfg.Probes))
+	for name := range cfg.Probes {
+		probeNames = append(probeNames, name)
+	}
+	sort.Strings(probeNames)
+	for _, name := range probeNames {
+		probe := cfg.Probes[name]
+		if err := probe.Validate(); err != nil {
+			addIssue("probes", "%s: %v", name, err)
+		}
+	}
+
+	for _, label := range cfg.Labels {
+		if err := validateLabel(label); err != nil {
+			addIssue("labels", "%v", err)
+		}
+	}
+
+	return issues, nil
+}
+
+// origin returns the last file that set the top level key
+func origin(sources []source, key string) string {
+	for i := len(sources) - 1; i >= 0; i-- {
+		if _, ok := sources[i].Values[key]; ok {
+			return sources[i].File
+		}
+	}
+	return ""
+}
+
+// unknownKeys walks value and returns the keys that have no json field in t
+func unknownKeys(t reflect.Type, value interface{}, path string) (result []string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := jsonFields(t)
+		for _, k := range sortedKeys(values) {
+			fieldType, ok := fields[k]
+			if !ok {
+				result = append(result, path+k)
+				continue
+			}
+			result = append(result, unknownKeys(fieldType, values[k], path+k+".")...)
+		}
+	case reflect.Slice:
+		values, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i, v := range values {
+			result = append(result, unknownKeys(t.Elem(), v, fmt.Sprintf("%s[%d].", strings.TrimSuffix(path, "."), i))...)
+		}
+	case reflect.Map:
+		values, ok := value.(map[string]interface{})
+		if !ok || t.Elem().Kind() == reflect.Interface {
+			return nil
+		}
+		for _, k := range sortedKeys(values) {
+			result = append(result, unknownKeys(t.Elem(), values[k], path+k+".")...)
+		}
+	}
+
+	return result
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonFields returns the json name and type of every field of t including embedded structs
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	result := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			for k, v := range jsonFields(field.Type) {
+				result[k] = v
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		result[name] = field.Type
+	}
+	return result
+}
+
+// validateTaint checks a taint in the key[=value]:effect format
+func validateTaint(taint string) error {
+	keyValue, effect, ok := strings.Cut(taint, ":")
+	if !ok {
+		return fmt.Errorf("invalid taint %q, must be in the format key[=value]:effect", taint)
+	}
+
+	key, value, _ := strings.Cut(keyValue, "=")
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return fmt.Errorf("invalid taint key in %q: %s", taint, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+		return fmt.Errorf("invalid taint value in %q: %s", taint, strings.Join(errs, ", "))
+	}
+	for _, valid := range taintEffects {
+		if effect == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid taint effect in %q, must be one of %s", taint, strings.Join(taintEffects, ", "))
+}
+
+// validateLabel checks a label in the key=value format
+func validateLabel(label string) error {
+	key, value, ok := strings.Cut(label, "=")
+	if !ok {
+		return fmt.Errorf("invalid label %q, must be in the format key=value", label)
+	}
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return fmt.Errorf("invalid label key in %q: %s", label, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+		return fmt.Errorf("invalid label value in %q: %s", label, strings.Join(errs, ", "))
+	}
+	return nil
+}
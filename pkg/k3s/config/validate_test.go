@@ -0,0 +1,128 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	const valid = "role: cluster-init\nkubernetesVersion: v1.28.4+k3s2\n"
+
+	tests := []struct {
+		name   string
+		config string
+		dotD   map[string]string
+		// expected are the keys of the issues, prefixed with the .d file that set them
+		expected []string
+	}{
+		{
+			name:   "valid",
+			config: valid,
+		},
+		{
+			name:   "channel",
+			config: "role: cluster-init\nkubernetesVersion: stable\n",
+		},
+		{
+			name:   "unknown nested keys",
+			config: valid + "kuberay:\n  apiserver:\n    chartz: kuberay-apiserver\nbogus: true\n",
+			dotD: map[string]string{
+				"10-periodic.yaml": "periodicInstructions:\n- name: check\n  command: /bin/true\n  periodSecond: 10\n",
+			},
+			expected: []string{"bogus", "kuberay.apiserver.chartz", "10-periodic.yaml:periodicInstructions[0].periodSecond"},
+		},
+		{
+			name:   "cloud-config is skipped",
+			config: valid,
+			dotD: map[string]string{
+				"10-user-data.yaml": "#cloud-config\nruncmd:\n- echo hello\nssh_authorized_keys: []\n",
+			},
+		},
+		{
+			name:   "okr key of a cloud-config is validated",
+			config: valid,
+			dotD: map[string]string{
+				"10-user-data.yaml": "#cloud-config\nruncmd:\n- echo hello\nokr:\n  bogus: true\n",
+			},
+			expected: []string{"10-user-data.yaml:bogus"},
+		},
+		{
+			name:     "server and token are required to join",
+			config:   "role: agent\nkubernetesVersion: v1.28.4+k3s2\n",
+			expected: []string{"server", "token"},
+		},
+		{
+			name:   "join",
+			config: "role: etcd,control-plane\nserver: https://server:6443\ntoken: secret\n",
+		},
+		{
+			name:     "invalid role",
+			config:   "role: cluster-init,worker\n",
+			expected: []string{"role"},
+		},
+		{
+			name:     "invalid version",
+			config:   "role: cluster-init\nkubernetesVersion: v1.28.bad\n",
+			expected: []string{"kubernetesVersion"},
+		},
+		{
+			name:     "invalid channel name",
+			config:   "role: cluster-init\nkubernetesVersion: Stable_Channel\n",
+			expected: []string{"kubernetesVersion"},
+		},
+		{
+			name:     "invalid channels",
+			config:   valid + "channels:\n  server: not-a-url\n  cacheTTL: soon\n",
+			expected: []string{"channels", "channels"},
+		},
+		{
+			name:   "version set in a .d file",
+			config: "role: cluster-init\n",
+			dotD: map[string]string{
+				"10-version.yaml": "kubernetesVersion: v1.28.bad\n",
+			},
+			expected: []string{"10-version.yaml:kubernetesVersion"},
+		},
+		{
+			name:     "invalid taints and labels",
+			config:   valid + "taints:\n- dedicated=infra:Sometimes\nlabels:\n- novalue\n",
+			expected: []string{"taints", "labels"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigDir(t, tt.config, tt.dotD)
+
+			issues, err := Validate(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var keys []string
+			for _, issue := range issues {
+				key := issue.Key
+				switch {
+				case issue.File == "", issue.File == path:
+				case filepath.Dir(issue.File) == path+".d":
+					key = filepath.Base(issue.File) + ":" + key
+				default:
+					t.Errorf("unexpected file of issue %s", issue)
+				}
+				keys = append(keys, key)
+			}
+			if !reflect.DeepEqual(keys, tt.expected) {
+				t.Errorf("expected issues %v, got %v", tt.expected, issues)
+			}
+		})
+	}
+}
+
+func TestIssueString(t *testing.T) {
+	issue := Issue{Key: "token", Message: "token is required"}
+	if s := issue.String(); !strings.HasPrefix(s, "<merged config>: token:") {
+		t.Errorf("expected the merged config as the file, got %s", s)
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// WatchPaths returns the directories to watch for changes of the files read by Load and
+// a function that reports whether a changed path is one of these files
+func WatchPaths(path string) ([]string, func(string) bool) {
+	files := map[string]bool{}
+	dirs := map[string]bool{}
+	watched := map[string]bool{}
+
+	configFiles := append([]string{}, implicitPaths...)
+	if path != "" {
+		configFiles = append(configFiles, path)
+	}
+	for _, file := range configFiles {
+		file = filepath.Clean(file)
+		files[file] = true
+		watched[filepath.Dir(file)] = true
+		dirs[file+".d"] = true
+		watched[file+".d"] = true
+		// implicit paths can also be directories of config files
+		dirs[file] = true
+		watched[file] = true
+	}
+	for _, dir := range manifests {
+		dirs[filepath.Clean(dir)] = true
+		watched[filepath.Clean(dir)] = true
+	}
+
+	result := make([]string, 0, len(watched))
+	for dir := range watched {
+		result = append(result, dir)
+	}
+	sort.Strings(result)
+
+	return result, func(changed string) bool {
+		changed = filepath.Clean(changed)
+		if files[changed] || dirs[changed] {
+			return true
+		}
+		return dirs[filepath.Dir(changed)] && isYAML(changed)
+	}
+}
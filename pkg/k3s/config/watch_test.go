@@ -0,0 +1,57 @@
+package config
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestWatchPaths(t *testing.T) {
+	dirs, isConfig := WatchPaths("/etc/okr/config.yaml")
+
+	if !sort.StringsAreSorted(dirs) {
+		t.Errorf("expected sorted directories, got %v", dirs)
+	}
+	for _, expected := range []string{"/etc/okr", "/etc/okr/config.yaml.d", "/etc/oneblock-ai/okr/manifests", "/var/lib/cloud/instance"} {
+		found := false
+		for _, dir := range dirs {
+			found = found || dir == expected
+		}
+		if !found {
+			t.Errorf("expected %s to be watched, got %v", expected, dirs)
+		}
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{path: "/etc/okr/config.yaml", expected: true},
+		{path: "/etc/okr/../okr/config.yaml", expected: true},
+		{path: "/etc/okr/config.yaml.d", expected: true},
+		{path: "/etc/okr/config.yaml.d/10-registries.yaml", expected: true},
+		{path: "/etc/okr/config.yaml.d/20-token.yml", expected: true},
+		{path: "/etc/okr/config.yaml.d/.10-registries.yaml.swp"},
+		{path: "/etc/okr/other.yaml"},
+		{path: "/var/lib/cloud/instance/user-data.txt", expected: true},
+		{path: "/var/lib/cloud/instance/vendor-data.txt"},
+		{path: "/etc/oneblock-ai/okr/manifests/app.yaml", expected: true},
+		{path: "/etc/oneblock-ai/okr/manifests/README.md"},
+	}
+	for _, tt := range tests {
+		if changed := isConfig(tt.path); changed != tt.expected {
+			t.Errorf("%s: expected %t, got %t", tt.path, tt.expected, changed)
+		}
+	}
+}
+
+func TestWatchPathsWithoutConfig(t *testing.T) {
+	dirs, isConfig := WatchPaths("")
+	for _, dir := range dirs {
+		if dir == "." {
+			t.Errorf("expected no directory for an empty config path, got %v", dirs)
+		}
+	}
+	if isConfig("config.yaml") {
+		t.Error("expected a relative file not to be a config file")
+	}
+}
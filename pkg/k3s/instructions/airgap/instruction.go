@@ -0,0 +1,32 @@
+package airgap
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rancher/system-agent/pkg/applyinator"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/self"
+)
+
+// ToInstruction extracts the air-gap bundle, it has to run before the runtime is installed
+// as the installer image is only available from the bundle
+func ToInstruction(cfg *config.Airgap) (*applyinator.OneTimeInstruction, error) {
+	if !cfg.IsEnabled() {
+		return nil, nil
+	}
+
+	cmd, err := self.Self()
+	if err != nil {
+		return nil, fmt.Errorf("resolving location of %s: %w", os.Args[0], err)
+	}
+	return &applyinator.OneTimeInstruction{
+		CommonInstruction: applyinator.CommonInstruction{
+			Name:    "airgap",
+			Args:    []string{"bundle", "extract", cfg.Bundle},
+			Command: cmd,
+		},
+		SaveOutput: true,
+	}, nil
+}
@@ -0,0 +1,138 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/rancher/system-agent/pkg/prober"
+	"github.com/sirupsen/logrus"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+)
+
+const (
+	defaultTimeoutSeconds   = 1
+	defaultSuccessThreshold = 1
+	defaultFailureThreshold = 3
+)
+
+// doProbe runs the probe and updates status the same way prober.DoProbe does for HTTP probes,
+// TCP and exec probes are stopped when ctx is done
+func doProbe(ctx context.Context, probe config.Probe, status *prober.ProbeStatus, initial bool) error {
+	if probe.IsHTTP() {
+		if err := prober.DoProbe(probe.Probe, status, initial); err != nil {
+			return err
+		}
+		// prober.DoProbe only logs the output of a failed request
+		if status.SuccessCount == 0 {
+			return fmt.Errorf("HTTP GET %s failed", probe.HTTPGetAction.URL)
+		}
+		return nil
+	}
+
+	if initial {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(probe.InitialDelaySeconds) * time.Second):
+		}
+	}
+
+	timeout := probe.TimeoutSeconds
+	if timeout == 0 {
+		timeout = defaultTimeoutSeconds
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	var err error
+	switch {
+	case probe.TCPSocket != nil:
+		err = probeTCPSocket(ctx, probe.TCPSocket)
+	case probe.Exec != nil:
+		err = probeExec(ctx, probe.Exec)
+	}
+	updateStatus(probe, status, err == nil)
+	return err
+}
+
+func updateStatus(probe config.Probe, status *prober.ProbeStatus, success bool) {
+	successThreshold := probe.SuccessThreshold
+	if successThreshold == 0 {
+		successThreshold = defaultSuccessThreshold
+	}
+	failureThreshold := probe.FailureThreshold
+	if failureThreshold == 0 {
+		failureThreshold = defaultFailureThreshold
+	}
+
+	if success {
+		status.FailureCount = 0
+		if status.SuccessCount < successThreshold {
+			status.SuccessCount++
+		}
+		if status.SuccessCount >= successThreshold {
+			status.Healthy = true
+		}
+		return
+	}
+
+	status.SuccessCount = 0
+	if status.FailureCount < failureThreshold {
+		status.FailureCount++
+	}
+	if status.FailureCount >= failureThreshold {
+		status.Healthy = false
+	}
+}
+
+func probeTCPSocket(ctx context.Context, action *config.TCPSocketAction) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", action.Address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func probeExec(ctx context.Context, action *config.ExecAction) error {
+	cmd := exec.CommandContext(ctx, action.Command, action.Args...)
+	cmd.Env = append(os.Environ(), action.Env...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, output)
+	}
+	return nil
+}
+
+// doProbes runs all probes concurrently like prober.DoProbes and records the outcome in results
+func doProbes(ctx context.Context, probes map[string]config.Probe, results map[string]*Result, initial bool) {
+	var wg sync.WaitGroup
+
+	for probeName, probe := range probes {
+		result := results[probeName]
+		wg.Add(1)
+		go func(probeName string, probe config.Probe) {
+			defer wg.Done()
+
+			probeStatus := prober.ProbeStatus{
+				Healthy:      result.Healthy,
+				SuccessCount: result.ConsecutiveSuccesses,
+				FailureCount: result.ConsecutiveFailures,
+			}
+			probe.Name = probeName
+			if err := doProbe(ctx, probe, &probeStatus, initial); err != nil {
+				logrus.Debugf("Probe [%s] failed: %v", probeName, err)
+				result.Error = err.Error()
+			}
+
+			result.Healthy = probeStatus.Healthy
+			result.ConsecutiveSuccesses = probeStatus.SuccessCount
+			result.ConsecutiveFailures = probeStatus.FailureCount
+		}(probeName, probe)
+	}
+	wg.Wait()
+}
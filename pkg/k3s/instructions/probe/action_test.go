@@ -0,0 +1,33 @@
+package probe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/system-agent/pkg/prober"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+)
+
+func TestDoProbeExecStopsWithContext(t *testing.T) {
+	probe := config.Probe{
+		Probe: prober.Probe{TimeoutSeconds: 30},
+		Exec:  &config.ExecAction{Command: "sleep", Args: []string{"30"}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	status := prober.ProbeStatus{}
+	if err := doProbe(ctx, probe, &status, false); err == nil {
+		t.Fatal("expected the cancelled probe to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the probe to stop with the context, it ran for %s", elapsed)
+	}
+	if status.FailureCount != 1 {
+		t.Errorf("expected 1 failure, got %d", status.FailureCount)
+	}
+}
@@ -0,0 +1,90 @@
+package probe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/rancher/system-agent/pkg/applyinator"
+	"github.com/rancher/system-agent/pkg/prober"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+)
+
+// Result is the last known state of a probe, Error is the error of its last failed run
+type Result struct {
+	Name                 string `json:"name"`
+	Healthy              bool   `json:"healthy"`
+	Error                string `json:"error,omitempty"`
+	ConsecutiveSuccesses int    `json:"consecutiveSuccesses"`
+	ConsecutiveFailures  int    `json:"consecutiveFailures"`
+	TimeToHealthy        string `json:"timeToHealthy,omitempty"`
+}
+
+// LoadProbes returns the probes of the plan and of the custom probes file, the probes
+// file is optional
+func LoadProbes(planFile, probesFile string) (map[string]config.Probe, error) {
+	f, err := os.Open(planFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	plan := &applyinator.Plan{}
+	if err := json.NewDecoder(f).Decode(plan); err != nil {
+		return nil, fmt.Errorf("parsing plan %s: %w", planFile, err)
+	}
+
+	result := map[string]config.Probe{}
+	for name, probe := range plan.Probes {
+		result[name] = config.Probe{Probe: probe}
+	}
+
+	if probesFile == "" {
+		return result, nil
+	}
+	data, err := os.ReadFile(probesFile)
+	if os.IsNotExist(err) {
+		return result, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	custom := map[string]config.Probe{}
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("parsing probes %s: %w", probesFile, err)
+	}
+	for name, probe := range custom {
+		result[name] = probe
+	}
+	return result, nil
+}
+
+// CheckOnce runs every probe a single time, ignoring the initial delay and thresholds
+func CheckOnce(ctx context.Context, probes map[string]config.Probe) []Result {
+	names := make([]string, 0, len(probes))
+	for name := range probes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]Result, 0, len(names))
+	for _, name := range names {
+		p := probes[name]
+		p.SuccessThreshold = 1
+		p.FailureThreshold = 1
+
+		status := prober.ProbeStatus{}
+		result := Result{Name: name}
+		if err := doProbe(ctx, p, &status, false); err != nil {
+			result.Error = err.Error()
+		}
+		result.Healthy = status.Healthy
+		result.ConsecutiveSuccesses = status.SuccessCount
+		result.ConsecutiveFailures = status.FailureCount
+		results = append(results, result)
+	}
+	return results
+}
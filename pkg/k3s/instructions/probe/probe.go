@@ -1,18 +1,25 @@
 package probe
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/rancher/system-agent/pkg/applyinator"
 	"github.com/rancher/system-agent/pkg/prober"
 
 	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/resources"
 	"github.com/oneblock-ai/okr/pkg/k3s/instructions/roles"
+	"github.com/oneblock-ai/okr/pkg/k3s/kubectl"
 	"github.com/oneblock-ai/okr/pkg/k3s/self"
 )
 
+const kubeRayOperatorProbe = "kuberay-operator"
+
 var probes = map[string]prober.Probe{
 	"kube-apiserver": {
 		InitialDelaySeconds: 1,
@@ -46,6 +53,18 @@ var probes = map[string]prober.Probe{
 			Insecure: true,
 		},
 	},
+	"etcd": {
+		InitialDelaySeconds: 1,
+		TimeoutSeconds:      5,
+		SuccessThreshold:    1,
+		FailureThreshold:    2,
+		HTTPGetAction: prober.HTTPGetAction{
+			URL:        "https://127.0.0.1:2379/health",
+			CACert:     "/var/lib/rancher/%s/server/tls/etcd/server-ca.crt",
+			ClientCert: "/var/lib/rancher/%s/server/tls/etcd/server-client.crt",
+			ClientKey:  "/var/lib/rancher/%s/server/tls/etcd/server-client.key",
+		},
+	},
 	"kubelet": {
 		InitialDelaySeconds: 1,
 		TimeoutSeconds:      5,
@@ -57,11 +76,8 @@ var probes = map[string]prober.Probe{
 	},
 }
 
-func AllProbes(runtime config.Runtime) map[string]prober.Probe {
-	return replaceRuntimeForProbes(probes, runtime)
-}
-
-func ToInstruction() (*applyinator.OneTimeInstruction, error) {
+// ToInstruction waits for the probes of the plan and the custom probes file in dataDir
+func ToInstruction(dataDir string) (*applyinator.OneTimeInstruction, error) {
 	cmd, err := self.Self()
 	if err != nil {
 		return nil, fmt.Errorf("fialed to resolve location of %s: %w", os.Args[0], err)
@@ -69,39 +85,111 @@ func ToInstruction() (*applyinator.OneTimeInstruction, error) {
 	return &applyinator.OneTimeInstruction{
 		CommonInstruction: applyinator.CommonInstruction{
 			Name:    "probes",
-			Args:    []string{"probe"},
+			Args:    []string{"probe", "--probes-file", GetProbesFile(dataDir)},
 			Command: cmd,
 		},
 		SaveOutput: true,
 	}, nil
 }
 
-func ProbesForJoin(cfg *config.RuntimeConfig) map[string]prober.Probe {
-	if roles.IsControlPlane(cfg.Role) {
-		return AllProbes(config.RuntimeUnknown)
+// ForRole returns the built-in probes of the components the role runs. The cluster-init
+// node also waits for the KubeRay operator it deploys to become available
+func ForRole(role roles.Role, k8sVersion string, kubeRay *config.KubeRay) map[string]config.Probe {
+	runtime := config.GetRuntime(k8sVersion)
+	names := []string{"kubelet"}
+	if role.ControlPlane {
+		names = append(names, "kube-apiserver", "kube-scheduler", "kube-controller-manager")
+	}
+	if role.Etcd {
+		names = append(names, "etcd")
+	}
+
+	result := map[string]config.Probe{}
+	for _, name := range names {
+		if probe, ok := replaceRuntimeForProbe(probes[name], runtime); ok {
+			result[name] = config.Probe{Probe: probe}
+		}
 	}
-	return replaceRuntimeForProbes(map[string]prober.Probe{
-		"kubelet": probes["kubelet"],
-	}, config.RuntimeUnknown)
+
+	if role.ClusterInit && kubeRay.IsEnabled() {
+		result[kubeRayOperatorProbe] = kubeRayProbe(k8sVersion, kubeRay)
+	}
+	return result
 }
 
-func replaceRuntimeForProbes(probes map[string]prober.Probe, runtime config.Runtime) map[string]prober.Probe {
+// kubeRayProbe waits for the operator Deployment of the KubeRay chart to become Available
+func kubeRayProbe(k8sVersion string, kubeRay *config.KubeRay) config.Probe {
+	operator := resources.KubeRayCharts(kubeRay)[0]
+	return config.Probe{
+		Probe: prober.Probe{
+			InitialDelaySeconds: 1,
+			TimeoutSeconds:      10,
+			SuccessThreshold:    1,
+			FailureThreshold:    2,
+		},
+		Exec: &config.ExecAction{
+			Command: kubectl.Command(k8sVersion),
+			Args: []string{
+				"wait", "deployment",
+				"--namespace", operator.TargetNamespace,
+				"--selector", "app.kubernetes.io/name=" + resources.KubeRayOperatorChart,
+				"--for", "condition=Available",
+				// only check once, the prober retries
+				"--timeout", "0s",
+			},
+			Env: kubectl.Env(k8sVersion),
+		},
+	}
+}
+
+// HTTPProbes returns the probes that can be run by the system agent prober
+func HTTPProbes(probes map[string]config.Probe) map[string]prober.Probe {
 	result := map[string]prober.Probe{}
-	for k, v := range probes {
-		// we don't know the runtime to find the file
-		if runtime == config.RuntimeUnknown && (v.HTTPGetAction.CACert+
-			v.HTTPGetAction.ClientCert+
-			v.HTTPGetAction.ClientKey) != "" {
-			continue
+	for name, probe := range probes {
+		if probe.IsHTTP() {
+			result[name] = probe.Probe
 		}
-		v.HTTPGetAction.CACert = replaceRuntime(v.HTTPGetAction.CACert, runtime)
-		v.HTTPGetAction.ClientCert = replaceRuntime(v.HTTPGetAction.ClientCert, runtime)
-		v.HTTPGetAction.ClientKey = replaceRuntime(v.HTTPGetAction.ClientKey, runtime)
-		result[k] = v
 	}
 	return result
 }
 
+// ToFile writes the probes that are not HTTP probes, it is always written so probes
+// removed from the config don't linger
+func ToFile(probes map[string]config.Probe, dataDir string) (*applyinator.File, error) {
+	result := map[string]config.Probe{}
+	for name, probe := range probes {
+		if !probe.IsHTTP() {
+			result[name] = probe
+		}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return &applyinator.File{
+		Content: base64.StdEncoding.EncodeToString(data),
+		Path:    GetProbesFile(dataDir),
+	}, nil
+}
+
+func GetProbesFile(dataDir string) string {
+	return filepath.Join(dataDir, "probes.json")
+}
+
+func replaceRuntimeForProbe(probe prober.Probe, runtime config.Runtime) (prober.Probe, bool) {
+	// we don't know the runtime to find the file
+	if runtime == config.RuntimeUnknown && (probe.HTTPGetAction.CACert+
+		probe.HTTPGetAction.ClientCert+
+		probe.HTTPGetAction.ClientKey) != "" {
+		return probe, false
+	}
+	probe.HTTPGetAction.CACert = replaceRuntime(probe.HTTPGetAction.CACert, runtime)
+	probe.HTTPGetAction.ClientCert = replaceRuntime(probe.HTTPGetAction.ClientCert, runtime)
+	probe.HTTPGetAction.ClientKey = replaceRuntime(probe.HTTPGetAction.ClientKey, runtime)
+	return probe, true
+}
+
 func replaceRuntime(str string, runtime config.Runtime) string {
 	if !strings.Contains(str, "%s") {
 		return str
@@ -0,0 +1,77 @@
+package probe
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/roles"
+)
+
+func TestForRole(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name    string
+		role    roles.Role
+		kubeRay config.KubeRay
+		want    []string
+	}{
+		{
+			name: "cluster-init",
+			role: roles.Role{ClusterInit: true, Etcd: true, ControlPlane: true, Worker: true},
+			want: []string{"etcd", "kube-apiserver", "kube-controller-manager", "kube-scheduler", "kubelet", kubeRayOperatorProbe},
+		},
+		{
+			name:    "cluster-init without kuberay",
+			role:    roles.Role{ClusterInit: true, Etcd: true, ControlPlane: true, Worker: true},
+			kubeRay: config.KubeRay{Enabled: &disabled},
+			want:    []string{"etcd", "kube-apiserver", "kube-controller-manager", "kube-scheduler", "kubelet"},
+		},
+		{
+			name: "etcd only",
+			role: roles.Role{Etcd: true},
+			want: []string{"etcd", "kubelet"},
+		},
+		{
+			name: "control-plane only",
+			role: roles.Role{ControlPlane: true},
+			want: []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler", "kubelet"},
+		},
+		{
+			name: "worker",
+			role: roles.Role{Worker: true},
+			want: []string{"kubelet"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ForRole(tt.role, "v1.28.4+rke2r1", &tt.kubeRay)
+			var got []string
+			for name := range result {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected probes %v, got %v", tt.want, got)
+			}
+			if etcd, ok := result["etcd"]; ok && !strings.Contains(etcd.HTTPGetAction.CACert, "/rke2/") {
+				t.Errorf("expected the rke2 etcd certificates, got %s", etcd.HTTPGetAction.CACert)
+			}
+		})
+	}
+}
+
+func TestForRoleUnknownRuntime(t *testing.T) {
+	// the certificates can't be found without the runtime
+	result := ForRole(roles.Role{Etcd: true, ControlPlane: true}, "", &config.KubeRay{})
+	for _, name := range []string{"etcd", "kube-apiserver"} {
+		if _, ok := result[name]; ok {
+			t.Errorf("expected the %s probe to be skipped", name)
+		}
+	}
+	if _, ok := result["kube-scheduler"]; !ok {
+		t.Error("expected the kube-scheduler probe")
+	}
+}
@@ -2,68 +2,118 @@ package probe
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"os"
+	"sort"
+	"strings"
 	"time"
 
-	"github.com/rancher/system-agent/pkg/applyinator"
-	"github.com/rancher/system-agent/pkg/prober"
 	"github.com/sirupsen/logrus"
 )
 
-func RunProbes(ctx context.Context, planFile string, interval time.Duration) error {
-	f, err := os.Open(planFile)
+type RunConfig struct {
+	// Interval is the time between two rounds of probes
+	Interval time.Duration
+	// Timeout bounds how long to wait for the probes to become healthy, 0 waits forever
+	Timeout time.Duration
+	// Once runs every probe a single time ignoring the thresholds
+	Once bool
+}
+
+// RunProbes waits until the probes of the plan and the custom probes file are healthy and
+// returns their last status, an error is returned if they are not all healthy
+func RunProbes(ctx context.Context, planFile, probesFile string, run RunConfig) ([]Result, error) {
+	probes, err := LoadProbes(planFile, probesFile)
 	if err != nil {
-		return fmt.Errorf("opening plan %s: %w", planFile, err)
+		return nil, err
 	}
-	defer f.Close()
 
-	plan := &applyinator.Plan{}
-	if err := json.NewDecoder(f).Decode(plan); err != nil {
-		return err
+	if len(probes) == 0 {
+		logrus.Infof("No probes defined in %s", planFile)
+		return nil, nil
 	}
 
-	if len(plan.Probes) == 0 {
-		logrus.Infof("No probes defined in %s", planFile)
-		return nil
+	if run.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, run.Timeout)
+		defer cancel()
 	}
+
+	if run.Once {
+		results := CheckOnce(ctx, probes)
+		return results, unhealthyError(results, "")
+	}
+
 	logrus.Infof("Running probes defined in %s", planFile)
 
+	start := time.Now()
 	initial := true
-	probeStatuses := map[string]prober.ProbeStatus{}
+	results := map[string]*Result{}
+	for name := range probes {
+		results[name] = &Result{Name: name}
+	}
 	for {
-		newProbeStatuses := map[string]prober.ProbeStatus{}
-		for k, v := range probeStatuses {
-			newProbeStatuses[k] = v
+		previous := map[string]bool{}
+		for name, result := range results {
+			previous[name] = result.Healthy
 		}
-		prober.DoProbes(plan.Probes, newProbeStatuses, initial)
+		doProbes(ctx, probes, results, initial)
 
 		allGood := true
-		for probeName, probeStatus := range newProbeStatuses {
-			if !probeStatus.Healthy {
+		for probeName, result := range results {
+			if !result.Healthy {
 				allGood = false
 			}
 
-			oldProbeStatus, ok := probeStatuses[probeName]
-			if !ok || oldProbeStatus.Healthy != probeStatus.Healthy {
-				if probeStatus.Healthy {
+			if initial || previous[probeName] != result.Healthy {
+				if result.Healthy {
 					logrus.Infof("Probe [%s] is healthy", probeName)
 				} else {
 					logrus.Warnf("Probe [%s] is unhealthy", probeName)
 				}
 			}
+			if result.Healthy && result.TimeToHealthy == "" {
+				result.TimeToHealthy = time.Since(start).Round(time.Millisecond).String()
+			}
 		}
 
 		if allGood {
 			logrus.Info("All probes are healthy")
-			break
+			return sortedResults(results), nil
 		}
 
-		probeStatuses = newProbeStatuses
 		initial = false
-		time.Sleep(interval)
+		select {
+		case <-ctx.Done():
+			sorted := sortedResults(results)
+			if ctx.Err() == context.DeadlineExceeded && run.Timeout > 0 {
+				return sorted, unhealthyError(sorted, fmt.Sprintf(" after %s", run.Timeout))
+			}
+			return sorted, ctx.Err()
+		case <-time.After(run.Interval):
+		}
+	}
+}
+
+func sortedResults(results map[string]*Result) []Result {
+	sorted := make([]Result, 0, len(results))
+	for _, result := range results {
+		sorted = append(sorted, *result)
 	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
 
-	return nil
+func unhealthyError(results []Result, suffix string) error {
+	var unhealthy []string
+	for _, result := range results {
+		if !result.Healthy {
+			unhealthy = append(unhealthy, result.Name)
+		}
+	}
+	if len(unhealthy) == 0 {
+		return nil
+	}
+	return fmt.Errorf("probes %s are not healthy%s", strings.Join(unhealthy, ", "), suffix)
 }
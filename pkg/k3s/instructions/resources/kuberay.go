@@ -0,0 +1,142 @@
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/utils"
+)
+
+const (
+	DefaultKubeRayVersion   = "1.0.0"
+	defaultKubeRayRepo      = "https://ray-project.github.io/kuberay-helm"
+	defaultKubeRayNamespace = "kuberay-system"
+	KubeRayOperatorChart    = "kuberay-operator"
+	KubeRayAPIServerChart   = "kuberay-apiserver"
+	HelmChartNamespace      = "kube-system"
+	kubeRayImageFormat      = "quay.io/kuberay/%s:v%s"
+	// the runtime serves the charts in its static charts dir from the apiserver
+	staticChartURLFormat = "https://%%{KUBERNETES_API}%%/static/charts/%s"
+)
+
+// KubeRayChart is a KubeRay HelmChart with the defaults applied
+type KubeRayChart struct {
+	config.HelmChart
+	// Name is the name of the HelmChart object
+	Name string
+	// Image is the image deployed by the chart with its default values
+	Image string
+}
+
+// StaticChartFile is the file name of the chart archive in the static charts dir
+func (c *KubeRayChart) StaticChartFile() string {
+	return fmt.Sprintf("%s-%s.tgz", c.Chart, c.Version)
+}
+
+// KubeRayVersion returns the configured KubeRay operator version or the default one
+func KubeRayVersion(cfg *config.KubeRay) string {
+	if cfg.Version != "" {
+		return cfg.Version
+	}
+	return DefaultKubeRayVersion
+}
+
+// KubeRayCharts returns the enabled KubeRay charts with the defaults applied, the operator is always first
+func KubeRayCharts(cfg *config.KubeRay) []KubeRayChart {
+	if !cfg.IsEnabled() {
+		return nil
+	}
+
+	result := []KubeRayChart{{
+		HelmChart: withDefaults(cfg.HelmChart, KubeRayOperatorChart, KubeRayVersion(cfg)),
+		Name:      KubeRayOperatorChart,
+		Image:     fmt.Sprintf(kubeRayImageFormat, "operator", KubeRayVersion(cfg)),
+	}}
+	if cfg.APIServer != nil {
+		apiServer := withDefaults(*cfg.APIServer, KubeRayAPIServerChart, KubeRayVersion(cfg))
+		result = append(result, KubeRayChart{
+			HelmChart: apiServer,
+			Name:      KubeRayAPIServerChart,
+			Image:     fmt.Sprintf(kubeRayImageFormat, "apiserver", apiServer.Version),
+		})
+	}
+	return result
+}
+
+// kubeRayResources returns the namespaces and HelmCharts of KubeRay, in an air-gapped install
+// the charts are served from the static charts dir the bundle is extracted to
+func kubeRayResources(cfg *config.KubeRay, airgap bool) []utils.GenericMap {
+	var result []utils.GenericMap
+	namespaces := map[string]bool{}
+	for _, chart := range KubeRayCharts(cfg) {
+		if !namespaces[chart.TargetNamespace] {
+			namespaces[chart.TargetNamespace] = true
+			result = append(result, namespace(chart.TargetNamespace))
+		}
+		if airgap && !isChartURL(chart.Chart) {
+			chart.Chart = fmt.Sprintf(staticChartURLFormat, chart.StaticChartFile())
+		}
+		result = append(result, helmChart(chart.Name, chart.HelmChart))
+	}
+	return result
+}
+
+func withDefaults(chart config.HelmChart, name, version string) config.HelmChart {
+	if chart.Chart == "" {
+		chart.Chart = name
+	}
+	if chart.Repo == "" && !isChartURL(chart.Chart) {
+		chart.Repo = defaultKubeRayRepo
+	}
+	if chart.Version == "" {
+		chart.Version = version
+	}
+	if chart.TargetNamespace == "" {
+		chart.TargetNamespace = defaultKubeRayNamespace
+	}
+	return chart
+}
+
+func isChartURL(chart string) bool {
+	return strings.HasPrefix(chart, "https://") || strings.HasPrefix(chart, "http://")
+}
+
+func namespace(name string) utils.GenericMap {
+	return utils.GenericMap{
+		Data: map[string]interface{}{
+			"kind":       "Namespace",
+			"apiVersion": "v1",
+			"metadata": map[string]interface{}{
+				"name": name,
+			},
+		},
+	}
+}
+
+func helmChart(name string, chart config.HelmChart) utils.GenericMap {
+	spec := map[string]interface{}{
+		"chart":           chart.Chart,
+		"targetNamespace": chart.TargetNamespace,
+	}
+	// the helm controller installs a chart URL as is, repo and version only apply to chart names
+	if !isChartURL(chart.Chart) {
+		spec["repo"] = chart.Repo
+		spec["version"] = chart.Version
+	}
+	if chart.ValuesContent != "" {
+		spec["valuesContent"] = chart.ValuesContent
+	}
+
+	return utils.GenericMap{
+		Data: map[string]interface{}{
+			"kind":       "HelmChart",
+			"apiVersion": "helm.cattle.io/v1",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": HelmChartNamespace,
+			},
+			"spec": spec,
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package resources
+
+import (
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/utils"
+)
+
+const (
+	defaultRayVersion   = "2.9.0"
+	defaultRayImage     = "rayproject/ray"
+	defaultRayNamespace = "default"
+	gpuResourceName     = "nvidia.com/gpu"
+)
+
+func rayClusterResources(clusters []config.RayCluster) ([]utils.GenericMap, error) {
+	if err := config.ValidateRayClusters(clusters); err != nil {
+		return nil, err
+	}
+
+	var result []utils.GenericMap
+	namespaces := map[string]bool{defaultRayNamespace: true}
+	for _, cluster := range clusters {
+		if cluster.Namespace != "" && !namespaces[cluster.Namespace] {
+			namespaces[cluster.Namespace] = true
+			result = append(result, namespace(cluster.Namespace))
+		}
+		result = append(result, rayCluster(&cluster))
+	}
+	return result, nil
+}
+
+func rayCluster(cluster *config.RayCluster) utils.GenericMap {
+	rayVersion := cluster.RayVersion
+	if rayVersion == "" {
+		rayVersion = defaultRayVersion
+	}
+	image := cluster.Image
+	if image == "" {
+		image = defaultRayImage + ":" + rayVersion
+	}
+	ns := cluster.Namespace
+	if ns == "" {
+		ns = defaultRayNamespace
+	}
+
+	headGroup := map[string]interface{}{
+		"rayStartParams": rayStartParams(cluster.Head.RayStartParams, map[string]interface{}{
+			"dashboard-host": "0.0.0.0",
+		}),
+		"template": podTemplate("ray-head", image, &cluster.Head.Resources, []interface{}{
+			port("gcs-server", 6379),
+			port("dashboard", 8265),
+			port("client", 10001),
+		}),
+	}
+	if cluster.Head.ServiceType != "" {
+		headGroup["serviceType"] = cluster.Head.ServiceType
+	}
+
+	var workerGroups []interface{}
+	for _, group := range cluster.WorkerGroups {
+		groupImage := group.Image
+		if groupImage == "" {
+			groupImage = image
+		}
+		workerGroup := map[string]interface{}{
+			"groupName":      group.Name,
+			"rayStartParams": rayStartParams(group.RayStartParams, nil),
+			"template":       podTemplate("ray-worker", groupImage, &group.Resources, nil),
+		}
+		if group.Replicas != nil {
+			workerGroup["replicas"] = *group.Replicas
+		}
+		if group.MinReplicas != nil {
+			workerGroup["minReplicas"] = *group.MinReplicas
+		}
+		if group.MaxReplicas != nil {
+			workerGroup["maxReplicas"] = *group.MaxReplicas
+		}
+		workerGroups = append(workerGroups, workerGroup)
+	}
+
+	spec := map[string]interface{}{
+		"rayVersion":    rayVersion,
+		"headGroupSpec": headGroup,
+	}
+	if len(workerGroups) > 0 {
+		spec["workerGroupSpecs"] = workerGroups
+	}
+	if cluster.Autoscaling != nil {
+		spec["enableInTreeAutoscaling"] = true
+		options := map[string]interface{}{}
+		if cluster.Autoscaling.UpscalingMode != "" {
+			options["upscalingMode"] = cluster.Autoscaling.UpscalingMode
+		}
+		if cluster.Autoscaling.IdleTimeoutSeconds != nil {
+			options["idleTimeoutSeconds"] = *cluster.Autoscaling.IdleTimeoutSeconds
+		}
+		if len(options) > 0 {
+			spec["autoscalerOptions"] = options
+		}
+	}
+
+	return utils.GenericMap{
+		Data: map[string]interface{}{
+			"kind":       "RayCluster",
+			"apiVersion": "ray.io/v1",
+			"metadata": map[string]interface{}{
+				"name":      cluster.Name,
+				"namespace": ns,
+			},
+			"spec": spec,
+		},
+	}
+}
+
+func rayStartParams(params map[string]string, defaults map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for k, v := range defaults {
+		result[k] = v
+	}
+	for k, v := range params {
+		result[k] = v
+	}
+	return result
+}
+
+func podTemplate(name, image string, resources *config.RayResources, ports []interface{}) map[string]interface{} {
+	container := map[string]interface{}{
+		"name":  name,
+		"image": image,
+	}
+	if requirements := resourceList(resources); len(requirements) > 0 {
+		container["resources"] = map[string]interface{}{
+			"requests": requirements,
+			"limits":   requirements,
+		}
+	}
+	if len(ports) > 0 {
+		container["ports"] = ports
+	}
+
+	return map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": []interface{}{container},
+		},
+	}
+}
+
+func resourceList(resources *config.RayResources) map[string]interface{} {
+	result := map[string]interface{}{}
+	if resources.CPU != "" {
+		result["cpu"] = resources.CPU
+	}
+	if resources.Memory != "" {
+		result["memory"] = resources.Memory
+	}
+	if resources.GPU != "" {
+		result[gpuResourceName] = resources.GPU
+	}
+	return result
+}
+
+func port(name string, containerPort int) map[string]interface{} {
+	return map[string]interface{}{
+		"name":          name,
+		"containerPort": containerPort,
+	}
+}
@@ -0,0 +1,148 @@
+package resources
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/utils"
+)
+
+const expectedRayCluster = `
+apiVersion: ray.io/v1
+kind: RayCluster
+metadata:
+  name: ray
+  namespace: ray-system
+spec:
+  rayVersion: 2.9.0
+  enableInTreeAutoscaling: true
+  autoscalerOptions:
+    upscalingMode: Conservative
+    idleTimeoutSeconds: 60
+  headGroupSpec:
+    serviceType: NodePort
+    rayStartParams:
+      dashboard-host: 0.0.0.0
+      num-cpus: "0"
+    template:
+      spec:
+        containers:
+        - name: ray-head
+          image: rayproject/ray:2.9.0
+          resources:
+            requests:
+              cpu: "1"
+              memory: 2Gi
+            limits:
+              cpu: "1"
+              memory: 2Gi
+          ports:
+          - name: gcs-server
+            containerPort: 6379
+          - name: dashboard
+            containerPort: 8265
+          - name: client
+            containerPort: 10001
+  workerGroupSpecs:
+  - groupName: gpu
+    replicas: 1
+    minReplicas: 0
+    maxReplicas: 4
+    rayStartParams: {}
+    template:
+      spec:
+        containers:
+        - name: ray-worker
+          image: rayproject/ray:2.9.0-gpu
+          resources:
+            requests:
+              nvidia.com/gpu: "1"
+            limits:
+              nvidia.com/gpu: "1"
+`
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+// toObject round trips the map through JSON to compare it with the parsed YAML
+func toObject(t *testing.T, m utils.GenericMap) map[string]interface{} {
+	t.Helper()
+	data, err := json.Marshal(m.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]interface{}{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestRayClusterResources(t *testing.T) {
+	clusters := []config.RayCluster{
+		{
+			Name:      "ray",
+			Namespace: "ray-system",
+			Head: config.RayHeadGroup{
+				Resources:      config.RayResources{CPU: "1", Memory: "2Gi"},
+				RayStartParams: map[string]string{"num-cpus": "0"},
+				ServiceType:    "NodePort",
+			},
+			WorkerGroups: []config.RayWorkerGroup{{
+				Name:        "gpu",
+				Image:       "rayproject/ray:2.9.0-gpu",
+				Replicas:    int32Ptr(1),
+				MinReplicas: int32Ptr(0),
+				MaxReplicas: int32Ptr(4),
+				Resources:   config.RayResources{GPU: "1"},
+			}},
+			Autoscaling: &config.RayAutoscaling{UpscalingMode: "Conservative", IdleTimeoutSeconds: int32Ptr(60)},
+		},
+		{Name: "other", Namespace: "ray-system"},
+		{Name: "minimal"},
+	}
+
+	result, err := rayClusterResources(clusters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the namespace is created once before its first cluster, default is never created
+	if len(result) != 4 {
+		t.Fatalf("expected a namespace and 3 ray clusters, got %d resources", len(result))
+	}
+	if kind := result[0].Data["kind"]; kind != "Namespace" {
+		t.Errorf("expected the namespace first, got %v", kind)
+	}
+
+	want := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(expectedRayCluster), &want); err != nil {
+		t.Fatal(err)
+	}
+	if got := toObject(t, result[1]); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected ray cluster\nexpected: %v\ngot:      %v", want, got)
+	}
+
+	minimal := toObject(t, result[3])
+	metadata := minimal["metadata"].(map[string]interface{})
+	if metadata["namespace"] != defaultRayNamespace {
+		t.Errorf("expected the default namespace, got %v", metadata["namespace"])
+	}
+	spec := minimal["spec"].(map[string]interface{})
+	for _, key := range []string{"workerGroupSpecs", "enableInTreeAutoscaling", "autoscalerOptions"} {
+		if _, ok := spec[key]; ok {
+			t.Errorf("expected no %s, got %v", key, spec[key])
+		}
+	}
+}
+
+func TestRayClusterResourcesInvalid(t *testing.T) {
+	clusters := []config.RayCluster{{Name: "ray"}, {Name: "ray"}}
+	if _, err := rayClusterResources(clusters); err == nil {
+		t.Error("expected the duplicate ray cluster to be rejected")
+	}
+}
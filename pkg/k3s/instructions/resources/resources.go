@@ -4,7 +4,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/rancher/system-agent/pkg/applyinator"
 	"github.com/rancher/wrangler/v2/pkg/yaml"
@@ -14,22 +13,18 @@ import (
 	"github.com/oneblock-ai/okr/pkg/k3s/config"
 	"github.com/oneblock-ai/okr/pkg/k3s/images"
 	"github.com/oneblock-ai/okr/pkg/k3s/kubectl"
+	"github.com/oneblock-ai/okr/pkg/k3s/retry"
 	"github.com/oneblock-ai/okr/pkg/k3s/self"
 	"github.com/oneblock-ai/okr/pkg/utils"
 )
 
-func ToBootstrapFile(config *config.Config, path string) (*applyinator.File, error) {
-	nodeName := config.NodeName
-	if nodeName == "" {
-		hostname, err := os.Hostname()
-		if err != nil {
-			return nil, fmt.Errorf("looking up hostname: %w", err)
-		}
-		nodeName = strings.Split(hostname, ".")[0]
+func ToBootstrapFile(cfg *config.Config, path string) (*applyinator.File, error) {
+	nodeName, err := config.GetNodeName(&cfg.RuntimeConfig)
+	if err != nil {
+		return nil, err
 	}
 
-	resources := config.Resources
-	return ToFile(append(resources, utils.GenericMap{
+	resources := append(cfg.Resources, utils.GenericMap{
 		Data: map[string]interface{}{
 			"kind":       "Node",
 			"apiVersion": "v1",
@@ -40,31 +35,16 @@ func ToBootstrapFile(config *config.Config, path string) (*applyinator.File, err
 				},
 			},
 		},
-	}, utils.GenericMap{
-		Data: map[string]interface{}{
-			"kind":       "Namespace",
-			"apiVersion": "v1",
-			"metadata": map[string]interface{}{
-				"name": "kuberay-system",
-			},
-		},
-	}, utils.GenericMap{
-		Data: map[string]interface{}{
-			"kind":       "HelmChart",
-			"apiVersion": "helm.cattle.io/v1",
-			"metadata": map[string]interface{}{
-				"name":      "kuberay-operator",
-				"namespace": "kube-system",
-			},
-			"spec": map[string]interface{}{
-				"repo":            "https://ray-project.github.io/kuberay-helm",
-				"chart":           "kuberay-operator",
-				"targetNamespace": "kuberay-system",
-				"version":         "1.0.0",
-			},
-		},
-	}), path)
+	})
+	resources = append(resources, kubeRayResources(&cfg.KubeRay, cfg.Airgap.IsEnabled())...)
+
+	rayClusters, err := rayClusterResources(cfg.RayClusters)
+	if err != nil {
+		return nil, err
+	}
+	return ToFile(append(resources, rayClusters...), path)
 }
+
 func ToFile(resources []utils.GenericMap, path string) (*applyinator.File, error) {
 	if len(resources) == 0 {
 		return nil, nil
@@ -92,8 +72,10 @@ func GetBootstrapManifests(dataDir string) string {
 	return fmt.Sprintf("%s/bootstrapmanifests/okr.yaml", dataDir)
 }
 
-func ToInstruction(imageOverride, systemDefaultRegistry, k8sVersion, dataDir string) (*applyinator.OneTimeInstruction, error) {
+func ToInstruction(imageOverride, systemDefaultRegistry, k8sVersion, dataDir string, policy retry.Policy) (*applyinator.OneTimeInstruction, error) {
 	bootstrap := GetBootstrapManifests(dataDir)
+	args := append([]string{"retry"}, policy.Args()...)
+	args = append(args, "--", kubectl.Command(k8sVersion), "apply", "--validate=false", "-f", bootstrap)
 	cmd, err := self.Self()
 	if err != nil {
 		return nil, fmt.Errorf("resolving location of %s: %w", os.Args[0], err)
@@ -102,7 +84,7 @@ func ToInstruction(imageOverride, systemDefaultRegistry, k8sVersion, dataDir str
 		CommonInstruction: applyinator.CommonInstruction{
 			Name:    "bootstrap",
 			Image:   images.GetInstallerImage(imageOverride, systemDefaultRegistry, k8sVersion),
-			Args:    []string{"retry", kubectl.Command(k8sVersion), "apply", "--validate=false", "-f", bootstrap},
+			Args:    args,
 			Command: cmd,
 			Env:     kubectl.Env(k8sVersion),
 		},
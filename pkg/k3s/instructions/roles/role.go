@@ -1,23 +1,119 @@
 package roles
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
-func IsEtcd(role string) bool {
-	return strings.Contains(role, "server") ||
-		strings.Contains(role, "etcd") ||
-		strings.Contains(role, "cluster-init")
+const (
+	ClusterInit  = "cluster-init"
+	Server       = "server"
+	Etcd         = "etcd"
+	ControlPlane = "control-plane"
+	Worker       = "worker"
+	Agent        = "agent"
+)
+
+// Known are the role names that can be combined, comma separated, in the role of a node
+var Known = []string{
+	ClusterInit,
+	Server,
+	Etcd,
+	ControlPlane,
+	"controlplane",
+	Worker,
+	Agent,
+}
+
+// Role is the parsed role of a node
+type Role struct {
+	// ClusterInit initializes the cluster, there must be exactly one such node
+	ClusterInit  bool
+	Etcd         bool
+	ControlPlane bool
+	Worker       bool
+}
+
+// Parse parses a comma separated list of role names. cluster-init and server on their own
+// stand for all of etcd, control-plane and worker, combined with any of those they only add
+// the explicitly listed ones, e.g. cluster-init,etcd,control-plane initializes a cluster
+// on a node that runs no workloads
+func Parse(role string) (Role, error) {
+	names := split(role)
+	if len(names) == 0 {
+		return Role{}, fmt.Errorf("role is empty")
+	}
+
+	result := Role{}
+	full := false
+	for _, name := range names {
+		switch name {
+		case ClusterInit:
+			result.ClusterInit = true
+		case Server:
+			full = true
+		case Etcd:
+			result.Etcd = true
+		case ControlPlane, "controlplane":
+			result.ControlPlane = true
+		case Worker, Agent:
+			result.Worker = true
+		default:
+			return Role{}, fmt.Errorf("unknown role %q, must be one of %s", name, strings.Join(Known, ", "))
+		}
+	}
+
+	if full || (result.ClusterInit && !result.Etcd && !result.ControlPlane && !result.Worker) {
+		result.Etcd = true
+		result.ControlPlane = true
+		result.Worker = true
+	}
+
+	if result.ClusterInit && (!result.Etcd || !result.ControlPlane) {
+		return Role{}, fmt.Errorf("role %q is invalid, cluster-init requires etcd and control-plane to apply the bootstrap manifests", role)
+	}
+	return result, nil
+}
+
+// IsServer is true for nodes that run the runtime in server mode, every etcd or control-plane node is a server
+func (r Role) IsServer() bool {
+	return r.Etcd || r.ControlPlane
+}
+
+// IsEtcdOnly is true for dedicated etcd nodes
+func (r Role) IsEtcdOnly() bool {
+	return r.Etcd && !r.ControlPlane
+}
+
+// IsControlPlaneOnly is true for control-plane nodes that don't run etcd
+func (r Role) IsControlPlaneOnly() bool {
+	return r.ControlPlane && !r.Etcd
 }
 
-func IsControlPlane(role string) bool {
-	return strings.Contains(role, "server") ||
-		strings.Contains(role, "cluster-init") ||
-		strings.Contains(role, "control-plane") ||
-		strings.Contains(role, "controlplane")
+func (r Role) String() string {
+	var names []string
+	for _, role := range []struct {
+		name    string
+		enabled bool
+	}{
+		{ClusterInit, r.ClusterInit},
+		{Etcd, r.Etcd},
+		{ControlPlane, r.ControlPlane},
+		{Worker, r.Worker},
+	} {
+		if role.enabled {
+			names = append(names, role.name)
+		}
+	}
+	return strings.Join(names, ",")
 }
 
-func IsWorker(role string) bool {
-	return strings.Contains(role, "worker") ||
-		strings.Contains(role, "cluster-init") ||
-		strings.Contains(role, "agent") ||
-		strings.Contains(role, "server")
+func split(role string) (result []string) {
+	for _, name := range strings.Split(role, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			result = append(result, name)
+		}
+	}
+	return
 }
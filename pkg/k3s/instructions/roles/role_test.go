@@ -0,0 +1,75 @@
+package roles
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		role    string
+		want    Role
+		wantErr bool
+	}{
+		{role: "server", want: Role{Etcd: true, ControlPlane: true, Worker: true}},
+		{role: "agent", want: Role{Worker: true}},
+		{role: "worker", want: Role{Worker: true}},
+		{role: "cluster-init", want: Role{ClusterInit: true, Etcd: true, ControlPlane: true, Worker: true}},
+		{role: "cluster-init,etcd,control-plane", want: Role{ClusterInit: true, Etcd: true, ControlPlane: true}},
+		{role: "etcd,control-plane", want: Role{Etcd: true, ControlPlane: true}},
+		{role: " etcd , controlplane ", want: Role{Etcd: true, ControlPlane: true}},
+		{role: "etcd", want: Role{Etcd: true}},
+		{role: "control-plane", want: Role{ControlPlane: true}},
+		{role: "cluster-init,worker", wantErr: true},
+		{role: "cluster-init,etcd", wantErr: true},
+		{role: "master", wantErr: true},
+		{role: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			got, err := Parse(tt.role)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRole(t *testing.T) {
+	tests := []struct {
+		role             Role
+		server           bool
+		etcdOnly         bool
+		controlPlaneOnly bool
+		name             string
+	}{
+		{role: Role{Worker: true}, name: "worker"},
+		{role: Role{Etcd: true}, server: true, etcdOnly: true, name: "etcd"},
+		{role: Role{ControlPlane: true}, server: true, controlPlaneOnly: true, name: "control-plane"},
+		{role: Role{ClusterInit: true, Etcd: true, ControlPlane: true, Worker: true}, server: true, name: "cluster-init,etcd,control-plane,worker"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.role.IsServer(); got != tt.server {
+				t.Errorf("expected IsServer %v, got %v", tt.server, got)
+			}
+			if got := tt.role.IsEtcdOnly(); got != tt.etcdOnly {
+				t.Errorf("expected IsEtcdOnly %v, got %v", tt.etcdOnly, got)
+			}
+			if got := tt.role.IsControlPlaneOnly(); got != tt.controlPlaneOnly {
+				t.Errorf("expected IsControlPlaneOnly %v, got %v", tt.controlPlaneOnly, got)
+			}
+			if got := tt.role.String(); got != tt.name {
+				t.Errorf("expected %q, got %q", tt.name, got)
+			}
+		})
+	}
+}
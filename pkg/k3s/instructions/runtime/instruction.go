@@ -2,22 +2,42 @@ package runtime
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/rancher/system-agent/pkg/applyinator"
 
 	"github.com/oneblock-ai/okr/pkg/k3s/config"
 	"github.com/oneblock-ai/okr/pkg/k3s/images"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/roles"
 )
 
 func ToInstruction(cfg *config.RuntimeConfig, imageOverride string, systemDefaultRegistry string, k8sVersion string) (*applyinator.OneTimeInstruction, error) {
 	runtime := config.GetRuntime(k8sVersion)
+	envPrefix := strings.ToUpper(string(runtime))
+	if runtime == config.RuntimeUnknown {
+		envPrefix = strings.ToUpper(string(config.RuntimeK3S))
+	}
+
+	role, err := roles.Parse(cfg.Role)
+	if err != nil {
+		return nil, err
+	}
 
 	var env []string
 	if len(cfg.Server) != 0 {
-		env = addEnv(env, "K3S_URL", cfg.Server)
+		env = addEnv(env, envPrefix+"_URL", cfg.Server)
+	}
+
+	switch {
+	// the rke2 installer defaults to a server install, unlike k3s it can't tell an agent by the presence of the URL
+	case runtime == config.RuntimeRKE2 && !role.IsServer():
+		env = addEnv(env, "INSTALL_RKE2_TYPE", "agent")
+	// the k3s installer defaults to an agent install when the URL is set
+	case runtime != config.RuntimeRKE2 && role.IsServer() && len(cfg.Server) != 0:
+		env = addEnv(env, "INSTALL_K3S_EXEC", "server")
 	}
 
-	env = addEnv(env, "K3S_TOKEN", cfg.Token)
+	env = addEnv(env, envPrefix+"_TOKEN", cfg.Token)
 	env = addEnv(env, "RESTART_STAMP", images.GetInstallerImage(imageOverride, systemDefaultRegistry, k8sVersion))
 	return &applyinator.OneTimeInstruction{
 		CommonInstruction: applyinator.CommonInstruction{
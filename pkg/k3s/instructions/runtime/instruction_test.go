@@ -0,0 +1,69 @@
+package runtime
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+)
+
+func TestToInstructionEnv(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        config.RuntimeConfig
+		k8sVersion string
+		want       []string
+	}{
+		{
+			name:       "k3s cluster-init",
+			cfg:        config.RuntimeConfig{Role: "cluster-init", Token: "token"},
+			k8sVersion: "v1.28.4+k3s2",
+			want:       []string{"K3S_TOKEN=token"},
+		},
+		{
+			name:       "k3s server",
+			cfg:        config.RuntimeConfig{Role: "server", Server: "https://server:6443", Token: "token"},
+			k8sVersion: "v1.28.4+k3s2",
+			want:       []string{"K3S_URL=https://server:6443", "INSTALL_K3S_EXEC=server", "K3S_TOKEN=token"},
+		},
+		{
+			name:       "k3s agent",
+			cfg:        config.RuntimeConfig{Role: "agent", Server: "https://server:6443", Token: "token"},
+			k8sVersion: "v1.28.4+k3s2",
+			want:       []string{"K3S_URL=https://server:6443", "K3S_TOKEN=token"},
+		},
+		{
+			name:       "rke2 server",
+			cfg:        config.RuntimeConfig{Role: "etcd", Server: "https://server:9345", Token: "token"},
+			k8sVersion: "v1.28.4+rke2r1",
+			want:       []string{"RKE2_URL=https://server:9345", "RKE2_TOKEN=token"},
+		},
+		{
+			name:       "rke2 agent",
+			cfg:        config.RuntimeConfig{Role: "worker", Server: "https://server:9345", Token: "token"},
+			k8sVersion: "v1.28.4+rke2r1",
+			want:       []string{"RKE2_URL=https://server:9345", "INSTALL_RKE2_TYPE=agent", "RKE2_TOKEN=token"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instruction, err := ToInstruction(&tt.cfg, "installer:test", "", tt.k8sVersion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append(tt.want, "RESTART_STAMP=installer:test")
+			if !reflect.DeepEqual(instruction.Env, want) {
+				t.Errorf("expected env %v, got %v", want, instruction.Env)
+			}
+			if instruction.Name != string(config.GetRuntime(tt.k8sVersion)) {
+				t.Errorf("expected the instruction to be named after the runtime, got %s", instruction.Name)
+			}
+		})
+	}
+}
+
+func TestToInstructionInvalidRole(t *testing.T) {
+	if _, err := ToInstruction(&config.RuntimeConfig{Role: "master"}, "", "", "v1.28.4+rke2r1"); err == nil {
+		t.Error("expected the unknown role to be rejected")
+	}
+}
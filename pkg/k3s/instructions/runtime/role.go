@@ -10,20 +10,34 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/roles"
 )
 
 var (
 	normalizeNames = map[string]string{
 		"tlsSans":         "tls-san",
 		"nodeName":        "node-name",
-		"internalAddress": "internal-address",
+		"address":         "node-external-ip",
+		"internalAddress": "node-ip",
 		"taints":          "node-taint",
 		"labels":          "node-label",
 	}
+
+	etcdTaint         = "node-role.kubernetes.io/etcd=true:NoExecute"
+	controlPlaneTaint = "node-role.kubernetes.io/control-plane=true:NoSchedule"
 )
 
-func ToFile(config *config.RuntimeConfig, runtime config.Runtime, clusterInit bool) (*applyinator.File, error) {
-	data, err := ToConfig(config, clusterInit)
+func ToFile(cfg *config.RuntimeConfig, runtime config.Runtime) (*applyinator.File, error) {
+	role, err := roles.Parse(cfg.Role)
+	if err != nil {
+		return nil, err
+	}
+	// rke2 has no cluster-init flag, the first server always initializes etcd
+	if runtime == config.RuntimeRKE2 {
+		role.ClusterInit = false
+	}
+
+	data, err := ToConfig(cfg, role)
 	if err != nil {
 		return nil, err
 	}
@@ -33,9 +47,10 @@ func ToFile(config *config.RuntimeConfig, runtime config.Runtime, clusterInit bo
 	}, nil
 }
 
-func ToConfig(config *config.RuntimeConfig, clusterInit bool) ([]byte, error) {
+func ToConfig(config *config.RuntimeConfig, role roles.Role) ([]byte, error) {
 	configObjects := []interface{}{
 		config.ConfigValues,
+		nodeConfig(config, role),
 	}
 
 	result := map[string]interface{}{}
@@ -58,15 +73,106 @@ func ToConfig(config *config.RuntimeConfig, clusterInit bool) ([]byte, error) {
 			newKey := strings.ReplaceAll(convert.ToYAMLKey(k), "_", "-")
 			result[newKey] = v
 		}
+	}
 
-		if clusterInit {
-			result["cluster-init"] = "true"
-		}
+	if role.ClusterInit {
+		result["cluster-init"] = "true"
 	}
+	addRoleFlags(result, role)
 
 	return yaml.Marshal(result)
 }
 
+// nodeConfig returns the fields of the runtime config that are written to the runtime's config
+// file, the server and token are passed to the installer instead
+func nodeConfig(cfg *config.RuntimeConfig, role roles.Role) map[string]interface{} {
+	result := map[string]interface{}{}
+	if len(cfg.SANS) > 0 && role.IsServer() {
+		result["tlsSans"] = cfg.SANS
+	}
+	if cfg.NodeName != "" {
+		result["nodeName"] = cfg.NodeName
+	}
+	if cfg.Address != "" {
+		result["address"] = cfg.Address
+	}
+	if cfg.InternalAddress != "" {
+		result["internalAddress"] = cfg.InternalAddress
+	}
+	if len(cfg.Taints) > 0 {
+		result["taints"] = cfg.Taints
+	}
+	if len(cfg.Labels) > 0 {
+		result["labels"] = cfg.Labels
+	}
+	return result
+}
+
+// addRoleFlags disables the components of a server that are not part of its role and keeps
+// workloads off servers without the worker role
+func addRoleFlags(result map[string]interface{}, role roles.Role) {
+	if !role.IsServer() {
+		return
+	}
+
+	if role.IsEtcdOnly() {
+		result["disable-apiserver"] = true
+		result["disable-scheduler"] = true
+		result["disable-controller-manager"] = true
+	}
+	if role.IsControlPlaneOnly() {
+		result["disable-etcd"] = true
+	}
+
+	if !role.Worker {
+		taints := userTaints(result["node-taint"])
+		if role.Etcd {
+			taints = append(taints, etcdTaint)
+		}
+		if role.ControlPlane {
+			taints = append(taints, controlPlaneTaint)
+		}
+		result["node-taint"] = taints
+	}
+}
+
+// userTaints returns the taints set in the config, which may be a single taint or a list
+func userTaints(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	case []string:
+		result := make([]interface{}, 0, len(v))
+		for _, taint := range v {
+			result = append(result, taint)
+		}
+		return result
+	default:
+		return []interface{}{v}
+	}
+}
+
 func GetKubeRuntimeConfigLocation(runtime config.Runtime) string {
 	return fmt.Sprintf("/etc/rancher/%s/config.yaml.d/40-okr.yaml", runtime)
 }
+
+// GetUninstallScripts returns the uninstall scripts the runtime installer leaves behind for server and agent nodes
+func GetUninstallScripts(runtime config.Runtime) []string {
+	if runtime == config.RuntimeRKE2 {
+		return []string{
+			"/usr/local/bin/rke2-uninstall.sh",
+			"/opt/rke2/bin/rke2-uninstall.sh",
+			"/usr/bin/rke2-uninstall.sh",
+		}
+	}
+	return []string{
+		fmt.Sprintf("/usr/local/bin/%s-uninstall.sh", runtime),
+		fmt.Sprintf("/usr/local/bin/%s-agent-uninstall.sh", runtime),
+	}
+}
+
+func GetDataDir(runtime config.Runtime) string {
+	return fmt.Sprintf("/var/lib/rancher/%s", runtime)
+}
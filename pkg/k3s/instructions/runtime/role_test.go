@@ -0,0 +1,230 @@
+package runtime
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/roles"
+)
+
+func toConfig(t *testing.T, cfg *config.RuntimeConfig) map[string]interface{} {
+	t.Helper()
+	role, err := roles.Parse(cfg.Role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ToConfig(cfg, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestToConfigRoleFlags(t *testing.T) {
+	tests := []struct {
+		role string
+		want map[string]interface{}
+	}{
+		{
+			role: "server",
+			want: map[string]interface{}{},
+		},
+		{
+			role: "agent",
+			want: map[string]interface{}{},
+		},
+		{
+			role: "cluster-init",
+			want: map[string]interface{}{
+				"cluster-init": "true",
+			},
+		},
+		{
+			role: "cluster-init,etcd,control-plane",
+			want: map[string]interface{}{
+				"cluster-init": "true",
+				"node-taint":   []interface{}{etcdTaint, controlPlaneTaint},
+			},
+		},
+		{
+			role: "etcd,control-plane",
+			want: map[string]interface{}{
+				"node-taint": []interface{}{etcdTaint, controlPlaneTaint},
+			},
+		},
+		{
+			role: "etcd",
+			want: map[string]interface{}{
+				"disable-apiserver":          true,
+				"disable-scheduler":          true,
+				"disable-controller-manager": true,
+				"node-taint":                 []interface{}{etcdTaint},
+			},
+		},
+		{
+			role: "control-plane",
+			want: map[string]interface{}{
+				"disable-etcd": true,
+				"node-taint":   []interface{}{controlPlaneTaint},
+			},
+		},
+		{
+			role: "etcd,worker",
+			want: map[string]interface{}{
+				"disable-apiserver":          true,
+				"disable-scheduler":          true,
+				"disable-controller-manager": true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			got := toConfig(t, &config.RuntimeConfig{Role: tt.role})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestToConfigNodeConfig(t *testing.T) {
+	cfg := &config.RuntimeConfig{
+		Server:          "https://server:6443",
+		Token:           "secret",
+		SANS:            []string{"okr.example.com"},
+		NodeName:        "node-1",
+		Address:         "123.123.123.123",
+		InternalAddress: "10.0.0.1",
+		Taints:          []string{"dedicated=special-user:NoSchedule"},
+		Labels:          []string{"key=value"},
+		ConfigValues:    map[string]interface{}{"write-kubeconfig-mode": "0644"},
+	}
+
+	tests := []struct {
+		role string
+		want map[string]interface{}
+	}{
+		{
+			role: "server",
+			want: map[string]interface{}{
+				"tls-san":               []interface{}{"okr.example.com"},
+				"node-name":             "node-1",
+				"node-external-ip":      "123.123.123.123",
+				"node-ip":               "10.0.0.1",
+				"node-taint":            []interface{}{"dedicated=special-user:NoSchedule"},
+				"node-label":            []interface{}{"key=value"},
+				"write-kubeconfig-mode": "0644",
+			},
+		},
+		{
+			// agents don't serve the API, the SANs are only written for servers
+			role: "agent",
+			want: map[string]interface{}{
+				"node-name":             "node-1",
+				"node-external-ip":      "123.123.123.123",
+				"node-ip":               "10.0.0.1",
+				"node-taint":            []interface{}{"dedicated=special-user:NoSchedule"},
+				"node-label":            []interface{}{"key=value"},
+				"write-kubeconfig-mode": "0644",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			cfg := *cfg
+			cfg.Role = tt.role
+			got := toConfig(t, &cfg)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestToConfigRoleTaintsAfterUserTaints(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config.RuntimeConfig
+	}{
+		{
+			name: "taints",
+			cfg: &config.RuntimeConfig{
+				Role:   "etcd,control-plane",
+				Taints: []string{"dedicated=infra:NoSchedule"},
+			},
+		},
+		{
+			name: "extra config",
+			cfg: &config.RuntimeConfig{
+				Role:         "etcd,control-plane",
+				ConfigValues: map[string]interface{}{"node-taint": []interface{}{"dedicated=infra:NoSchedule"}},
+			},
+		},
+		{
+			name: "extra config single taint",
+			cfg: &config.RuntimeConfig{
+				Role:         "etcd,control-plane",
+				ConfigValues: map[string]interface{}{"node-taint": "dedicated=infra:NoSchedule"},
+			},
+		},
+	}
+
+	want := []interface{}{"dedicated=infra:NoSchedule", etcdTaint, controlPlaneTaint}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toConfig(t, tt.cfg)
+			if !reflect.DeepEqual(got["node-taint"], want) {
+				t.Errorf("expected taints %v, got %v", want, got["node-taint"])
+			}
+		})
+	}
+}
+
+func TestToFile(t *testing.T) {
+	tests := []struct {
+		runtime     config.Runtime
+		path        string
+		clusterInit bool
+	}{
+		{runtime: config.RuntimeK3S, path: "/etc/rancher/k3s/config.yaml.d/40-okr.yaml", clusterInit: true},
+		// rke2 has no cluster-init flag
+		{runtime: config.RuntimeRKE2, path: "/etc/rancher/rke2/config.yaml.d/40-okr.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.runtime), func(t *testing.T) {
+			file, err := ToFile(&config.RuntimeConfig{Role: "cluster-init"}, tt.runtime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Path != tt.path {
+				t.Errorf("expected path %s, got %s", tt.path, file.Path)
+			}
+			data, err := base64.StdEncoding.DecodeString(file.Content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := map[string]interface{}{}
+			if err := yaml.Unmarshal(data, &result); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := result["cluster-init"]; ok != tt.clusterInit {
+				t.Errorf("expected cluster-init %v, got %v", tt.clusterInit, result)
+			}
+		})
+	}
+
+	if _, err := ToFile(&config.RuntimeConfig{Role: "cluster-init,worker"}, config.RuntimeK3S); err == nil {
+		t.Error("expected an invalid role to fail")
+	}
+}
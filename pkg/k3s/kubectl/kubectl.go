@@ -7,10 +7,14 @@ import (
 	"github.com/oneblock-ai/okr/pkg/k3s/config"
 )
 
-const kubectlDefault = "/usr/local/bin/kubectl"
+const (
+	kubectlDefault = "/usr/local/bin/kubectl"
+	kubectlRKE2    = "/var/lib/rancher/rke2/bin/kubectl"
+)
 
 var kubeconfigs = []string{
 	"/etc/rancher/k3s/k3s.yaml",
+	"/etc/rancher/rke2/rke2.yaml",
 }
 
 func Env(k8sVersion string) []string {
@@ -24,9 +28,11 @@ func Env(k8sVersion string) []string {
 }
 
 func Command(k8sVersion string) string {
-	runtime := config.GetRuntime(k8sVersion)
-	if runtime == config.RuntimeK3S {
+	switch config.GetRuntime(k8sVersion) {
+	case config.RuntimeK3S:
 		return kubectlDefault
+	case config.RuntimeRKE2:
+		return kubectlRKE2
 	}
 	return "kubectl"
 }
@@ -2,16 +2,18 @@ package plan
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/rancher/system-agent/pkg/applyinator"
 
 	config2 "github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/airgap"
 	"github.com/oneblock-ai/okr/pkg/k3s/instructions/probe"
 	"github.com/oneblock-ai/okr/pkg/k3s/instructions/resources"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/roles"
 	runtime2 "github.com/oneblock-ai/okr/pkg/k3s/instructions/runtime"
 	"github.com/oneblock-ai/okr/pkg/k3s/kubectl"
-	"github.com/oneblock-ai/okr/pkg/k3s/registry"
 	"github.com/oneblock-ai/okr/pkg/k3s/versions"
 )
 
@@ -31,7 +33,7 @@ func toInitPlan(config *config2.Config, dataDir string) (*applyinator.Plan, erro
 		return nil, err
 	}
 
-	if err := plan.addProbes(config); err != nil {
+	if err := plan.addProbes(config, dataDir); err != nil {
 		return nil, err
 	}
 
@@ -58,16 +60,27 @@ func toJoinPlan(cfg *config2.Config, dataDir string) (*applyinator.Plan, error)
 	}
 
 	// add probes
-	if err := plan.addProbesForJoin(cfg); err != nil {
+	if err := plan.addProbes(cfg, dataDir); err != nil {
 		return nil, err
 	}
 
 	return (*applyinator.Plan)(&plan), nil
 }
 
+// ToPlan returns the plan of the config, the registries.yaml isn't part of it as it holds
+// the registry credentials and is written by Run instead
 func ToPlan(ctx context.Context, config *config2.Config, dataDir string) (*applyinator.Plan, error) {
 	newCfg := *config
-	if newCfg.Role == "cluster-init" {
+	if newCfg.Registries != nil {
+		if errs := newCfg.Registries.Validate(); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid registries: %w", errors.Join(errs...))
+		}
+	}
+	role, err := roles.Parse(newCfg.Role)
+	if err != nil {
+		return nil, err
+	}
+	if role.ClusterInit {
 		return toInitPlan(&newCfg, dataDir)
 	}
 	return toJoinPlan(&newCfg, dataDir)
@@ -79,24 +92,40 @@ func (p *plan) addInstructions(cfg *config2.Config, dataDir string, addResource
 		return err
 	}
 
-	// add runtime instruction, e.g., k3s
-	if err := p.addOneTimeInstruction(runtime2.ToInstruction(&cfg.RuntimeConfig, cfg.RuntimeInstallerImage, cfg.SystemDefaultRegistry, k8sVersion)); err != nil {
+	// add air-gap instruction, it provides the images of the runtime instruction
+	if err := p.addOneTimeInstruction(airgap.ToInstruction(&cfg.Airgap)); err != nil {
 		return err
 	}
 
-	// add probe instruction
-	if err := p.addOneTimeInstruction(probe.ToInstruction()); err != nil {
+	// add runtime instruction, e.g., k3s
+	if err := p.addOneTimeInstruction(runtime2.ToInstruction(&cfg.RuntimeConfig, cfg.RuntimeInstallerImage, cfg.SystemDefaultRegistry, k8sVersion)); err != nil {
 		return err
 	}
 
 	// add resource instruction
 	if addResource {
-		if err := p.addOneTimeInstruction(resources.ToInstruction(cfg.RuntimeInstallerImage, cfg.SystemDefaultRegistry, k8sVersion, dataDir)); err != nil {
+		policy, err := cfg.RetryPolicy.ResourcesPolicy()
+		if err != nil {
 			return err
 		}
+		if err := p.addOneTimeInstruction(resources.ToInstruction(cfg.RuntimeInstallerImage, cfg.SystemDefaultRegistry, k8sVersion, dataDir, policy)); err != nil {
+			return err
+		}
+	}
+
+	// add probe instruction, after the resources as the KubeRay probe waits for them
+	if err := p.addOneTimeInstruction(probe.ToInstruction(dataDir)); err != nil {
+		return err
 	}
 
 	p.addPrePostInstructions(cfg, k8sVersion)
+
+	for _, inst := range cfg.PeriodicInstructions {
+		inst.Env = append(inst.Env, kubectl.Env(k8sVersion)...)
+		if err := p.addPeriodInstruction(&inst, nil); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -148,12 +177,7 @@ func (p *plan) addFiles(cfg *config2.Config, dataDir string) error {
 	runtimeName := config2.GetRuntime(k8sVersions)
 
 	// config.yaml
-	if err := p.addFile(runtime2.ToFile(&cfg.RuntimeConfig, runtimeName, true)); err != nil {
-		return err
-	}
-
-	// registries.yaml
-	if err := p.addFile(registry.ToFile(cfg.Registries, runtimeName)); err != nil {
+	if err := p.addFile(runtime2.ToFile(&cfg.RuntimeConfig, runtimeName)); err != nil {
 		return err
 	}
 
@@ -172,9 +196,10 @@ func (p *plan) addJoinFiles(cfg *config2.Config, dataDir string) error {
 	runtimeName := config2.GetRuntime(k8sVersions)
 
 	// config.yaml
-	if err := p.addFile(runtime2.ToFile(&cfg.RuntimeConfig, runtimeName, false)); err != nil {
+	if err := p.addFile(runtime2.ToFile(&cfg.RuntimeConfig, runtimeName)); err != nil {
 		return err
 	}
+
 	return nil
 }
 
@@ -186,16 +211,24 @@ func (p *plan) addFile(file *applyinator.File, err error) error {
 	return nil
 }
 
-func (p *plan) addProbesForJoin(cfg *config2.Config) error {
-	p.Probes = probe.ProbesForJoin(&cfg.RuntimeConfig)
-	return nil
-}
-
-func (p *plan) addProbes(cfg *config2.Config) error {
+// addProbes adds the built-in probes of the node's role and the custom probes, the probes
+// the system agent prober can't run are written to the probes file
+func (p *plan) addProbes(cfg *config2.Config, dataDir string) error {
 	k8sVersion, err := versions.K8sVersion(cfg.KubernetesVersion)
 	if err != nil {
 		return err
 	}
-	p.Probes = probe.AllProbes(config2.GetRuntime(k8sVersion))
-	return nil
+	role, err := roles.Parse(cfg.Role)
+	if err != nil {
+		return err
+	}
+
+	probes := probe.ForRole(role, k8sVersion, &cfg.KubeRay)
+	for name, custom := range cfg.Probes {
+		probes[name] = custom
+	}
+	p.Probes = probe.HTTPProbes(probes)
+
+	// probes.json
+	return p.addFile(probe.ToFile(probes, dataDir))
 }
@@ -0,0 +1,62 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	config2 "github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/registry"
+)
+
+func registryConfig(registries *config2.Registry) *config2.Config {
+	return &config2.Config{
+		RuntimeConfig: config2.RuntimeConfig{
+			Role:     "cluster-init",
+			NodeName: "node1",
+			Token:    "token",
+		},
+		KubernetesVersion: k8sVersion,
+		Registries:        registries,
+	}
+}
+
+func TestToPlanLeavesOutRegistryCredentials(t *testing.T) {
+	t.Setenv("OKR_TEST_PASSWORD", "registry-secret")
+	cfg := registryConfig(&config2.Registry{
+		Configs: map[string]config2.RegistryConfig{"mirror.example.com": {
+			Auth: &config2.AuthConfig{Username: "okr", PasswordEnv: "OKR_TEST_PASSWORD"},
+		}},
+	})
+
+	nodePlan, err := ToPlan(context.Background(), cfg, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range nodePlan.Files {
+		if file.Path == registry.GetConfigFile(config2.RuntimeK3S) {
+			t.Errorf("expected no %s in the plan", file.Path)
+		}
+	}
+	rendered, err := Render(nodePlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "registry-secret") {
+		t.Errorf("expected no registry credentials in the plan, got %s", data)
+	}
+}
+
+func TestToPlanValidatesRegistries(t *testing.T) {
+	cfg := registryConfig(&config2.Registry{
+		Mirrors: map[string]config2.Mirror{"docker.io": {Endpoints: []string{"mirror.example.com"}}},
+	})
+	if _, err := ToPlan(context.Background(), cfg, t.TempDir()); err == nil || !strings.Contains(err.Error(), "invalid registries") {
+		t.Errorf("expected an error about the registries, got %v", err)
+	}
+}
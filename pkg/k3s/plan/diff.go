@@ -0,0 +1,132 @@
+package plan
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rancher/system-agent/pkg/applyinator"
+)
+
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Change is a file, instruction or probe that differs between two plans
+type Change struct {
+	// Kind is file, instruction, periodicInstruction or probe
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Operation string `json:"operation"`
+	// Diff shows the changed fields of a changed item
+	Diff string `json:"diff,omitempty"`
+}
+
+// Diff returns the changes from plan a to plan b with the file contents decoded
+func Diff(a, b *applyinator.Plan) ([]Change, error) {
+	renderedA, err := Render(a)
+	if err != nil {
+		return nil, err
+	}
+	renderedB, err := Render(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Change
+	result = append(result, diffItems("file", filesByPath(renderedA.Files), filesByPath(renderedB.Files))...)
+	result = append(result, diffItems("instruction", byName(renderedA.OneTimeInstructions, oneTimeName),
+		byName(renderedB.OneTimeInstructions, oneTimeName))...)
+	result = append(result, diffItems("periodicInstruction", byName(renderedA.PeriodicInstructions, periodicName),
+		byName(renderedB.PeriodicInstructions, periodicName))...)
+
+	probesA, probesB := map[string]interface{}{}, map[string]interface{}{}
+	for name, probe := range renderedA.Probes {
+		probesA[name] = probe
+	}
+	for name, probe := range renderedB.Probes {
+		probesB[name] = probe
+	}
+	result = append(result, diffItems("probe", probesA, probesB)...)
+	return result, nil
+}
+
+// diffFile splits the content of a file in lines to diff it line by line
+type diffFile struct {
+	RenderedFile
+	Content []string `json:"content,omitempty"`
+}
+
+func filesByPath(files []RenderedFile) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, file := range files {
+		result[file.Path] = diffFile{
+			RenderedFile: file,
+			Content:      strings.Split(file.Content, "\n"),
+		}
+	}
+	return result
+}
+
+func oneTimeName(i applyinator.OneTimeInstruction) string {
+	return i.Name
+}
+
+func periodicName(i applyinator.PeriodicInstruction) string {
+	return i.Name
+}
+
+func byName[T any](items []T, name func(T) string) map[string]interface{} {
+	result := map[string]interface{}{}
+	for _, item := range items {
+		result[name(item)] = item
+	}
+	return result
+}
+
+func diffItems(kind string, a, b map[string]interface{}) (result []Change) {
+	names := map[string]bool{}
+	for name := range a {
+		names[name] = true
+	}
+	for name := range b {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		itemA, inA := a[name]
+		itemB, inB := b[name]
+		switch {
+		case !inA:
+			result = append(result, Change{Kind: kind, Name: name, Operation: Added})
+		case !inB:
+			result = append(result, Change{Kind: kind, Name: name, Operation: Removed})
+		default:
+			if diff := cmp.Diff(toGeneric(itemA), toGeneric(itemB)); diff != "" {
+				result = append(result, Change{Kind: kind, Name: name, Operation: Changed, Diff: diff})
+			}
+		}
+	}
+	return result
+}
+
+// toGeneric converts item to its json form so that the diff uses the json field names
+func toGeneric(item interface{}) interface{} {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return item
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return item
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+package plan
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/rancher/system-agent/pkg/applyinator"
+	"github.com/rancher/system-agent/pkg/prober"
+)
+
+func file(path, content string) applyinator.File {
+	return applyinator.File{
+		Path:    path,
+		Content: base64.StdEncoding.EncodeToString([]byte(content)),
+	}
+}
+
+func instruction(name string, args ...string) applyinator.OneTimeInstruction {
+	return applyinator.OneTimeInstruction{
+		CommonInstruction: applyinator.CommonInstruction{Name: name, Command: "/bin/sh", Args: args},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := &applyinator.Plan{
+		Files: []applyinator.File{
+			file("/etc/rancher/k3s/config.yaml", "token: a\nnode-name: node1\n"),
+			file("/etc/removed", "removed"),
+			file("/etc/same", "same"),
+		},
+		OneTimeInstructions: []applyinator.OneTimeInstruction{
+			instruction("install", "-c", "install.sh"),
+			instruction("same"),
+		},
+		Probes: map[string]prober.Probe{
+			"kubelet": {Name: "kubelet"},
+		},
+	}
+	b := &applyinator.Plan{
+		Files: []applyinator.File{
+			file("/etc/rancher/k3s/config.yaml", "token: a\nnode-name: node2\n"),
+			file("/etc/added", "added"),
+			file("/etc/same", "same"),
+		},
+		OneTimeInstructions: []applyinator.OneTimeInstruction{
+			instruction("install", "-c", "install.sh --upgrade"),
+			instruction("same"),
+		},
+		PeriodicInstructions: []applyinator.PeriodicInstruction{
+			{CommonInstruction: applyinator.CommonInstruction{Name: "backup"}},
+		},
+	}
+
+	changes, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Change{
+		{Kind: "file", Name: "/etc/added", Operation: Added},
+		{Kind: "file", Name: "/etc/rancher/k3s/config.yaml", Operation: Changed},
+		{Kind: "file", Name: "/etc/removed", Operation: Removed},
+		{Kind: "instruction", Name: "install", Operation: Changed},
+		{Kind: "periodicInstruction", Name: "backup", Operation: Added},
+		{Kind: "probe", Name: "kubelet", Operation: Removed},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %+v", len(expected), changes)
+	}
+	for i, change := range changes {
+		diff := change.Diff
+		change.Diff = ""
+		if change != expected[i] {
+			t.Errorf("expected %+v at %d, got %+v", expected[i], i, change)
+		}
+		if (change.Operation == Changed) != (diff != "") {
+			t.Errorf("expected a diff only for a changed %s, got %q", change.Name, diff)
+		}
+	}
+
+	// the file content is decoded and diffed by line
+	for _, line := range strings.Split(changes[1].Diff, "\n") {
+		line = strings.TrimSpace(line)
+		if (strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+")) && !strings.Contains(line, "node-name") {
+			t.Errorf("expected only the node-name line to change, got %s", line)
+		}
+	}
+	if diff := changes[1].Diff; !strings.Contains(diff, "node-name: node2") {
+		t.Errorf("expected the decoded content in the diff, got\n%s", diff)
+	}
+}
+
+func TestDiffSamePlan(t *testing.T) {
+	plan := &applyinator.Plan{
+		Files:               []applyinator.File{file("/etc/same", "same")},
+		OneTimeInstructions: []applyinator.OneTimeInstruction{instruction("same")},
+	}
+	changes, err := Diff(plan, plan)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v, %v", changes, err)
+	}
+}
+
+func TestDiffInvalidContent(t *testing.T) {
+	plan := &applyinator.Plan{
+		Files: []applyinator.File{{Path: "/etc/invalid", Content: "not base64!"}},
+	}
+	if _, err := Diff(plan, &applyinator.Plan{}); err == nil {
+		t.Error("expected an error for content that isn't base64")
+	}
+}
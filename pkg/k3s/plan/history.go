@@ -0,0 +1,216 @@
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/system-agent/pkg/applyinator"
+)
+
+// historyLimit is the number of plans kept in the history
+const historyLimit = 20
+
+// Revision is a plan that was applied, the history is numbered from 1
+type Revision struct {
+	Number   int       `json:"number"`
+	Checksum string    `json:"checksum"`
+	Time     time.Time `json:"time"`
+	// File is the plan, OutputFile is its one time instruction output if it was saved
+	File       string `json:"file"`
+	OutputFile string `json:"outputFile,omitempty"`
+}
+
+func GetHistoryDir(dataDir string) string {
+	return filepath.Join(dataDir, "plan", "history")
+}
+
+// History returns the revisions of dataDir from the oldest to the latest
+func History(dataDir string) ([]Revision, error) {
+	dir := GetHistoryDir(dataDir)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var result []Revision
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || strings.HasSuffix(name, "-output") {
+			continue
+		}
+		number, checksum, ok := strings.Cut(name, "-")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		revision := Revision{
+			Number:   n,
+			Checksum: checksum,
+			Time:     info.ModTime(),
+			File:     filepath.Join(dir, entry.Name()),
+		}
+		if output := filepath.Join(dir, name+"-output.json"); fileExists(output) {
+			revision.OutputFile = output
+		}
+		result = append(result, revision)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Number < result[j].Number
+	})
+	return result, nil
+}
+
+// GetRevision returns the revision with the given number, the latest if number is 0 and
+// counting back from the latest if number is negative
+func GetRevision(dataDir string, number int) (*Revision, error) {
+	history, err := History(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("no plan history in %s", GetHistoryDir(dataDir))
+	}
+
+	if number <= 0 {
+		i := len(history) - 1 + number
+		if i < 0 {
+			return nil, fmt.Errorf("plan history only has %d revisions", len(history))
+		}
+		return &history[i], nil
+	}
+	for _, revision := range history {
+		if revision.Number == number {
+			return &revision, nil
+		}
+	}
+	return nil, fmt.Errorf("revision %d is not in the plan history, available are %d to %d",
+		number, history[0].Number, history[len(history)-1].Number)
+}
+
+// LoadRevision reads the plan of the revision
+func LoadRevision(revision *Revision) (*applyinator.Plan, error) {
+	data, err := os.ReadFile(revision.File)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &applyinator.Plan{}
+	if err := json.Unmarshal(data, plan); err != nil {
+		return nil, fmt.Errorf("parsing plan %s: %w", revision.File, err)
+	}
+	return plan, nil
+}
+
+// addRevision records the encoded plan as the latest revision unless it is already the latest
+func addRevision(dataDir string, data []byte) (*Revision, error) {
+	sum := checksum(data)
+
+	history, err := History(dataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	number := 1
+	if len(history) > 0 {
+		latest := history[len(history)-1]
+		if latest.Checksum == sum {
+			return &latest, nil
+		}
+		number = latest.Number + 1
+	}
+
+	dir := GetHistoryDir(dataDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	revision := &Revision{
+		Number:   number,
+		Checksum: sum,
+		Time:     time.Now(),
+		File:     filepath.Join(dir, fmt.Sprintf("%d-%s.json", number, sum)),
+	}
+	if err := os.WriteFile(revision.File, data, 0600); err != nil {
+		return nil, err
+	}
+
+	history = append(history, *revision)
+	for len(history) > historyLimit {
+		if err := removeRevision(history[0]); err != nil {
+			return nil, err
+		}
+		history = history[1:]
+	}
+	return revision, nil
+}
+
+// Checksum returns the checksum of the plan as written to the plan file and the history
+func Checksum(plan *applyinator.Plan) (string, error) {
+	data, err := encode(plan)
+	if err != nil {
+		return "", err
+	}
+	return checksum(data), nil
+}
+
+func checksum(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func removeRevision(revision Revision) error {
+	if err := os.Remove(revision.File); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if revision.OutputFile != "" {
+		if err := os.Remove(revision.OutputFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveRevisionOutput copies the plan output of dataDir to the revision
+func saveRevisionOutput(revision *Revision, dataDir string) error {
+	in, err := os.Open(GetPlanOutput(dataDir))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	revision.OutputFile = strings.TrimSuffix(revision.File, ".json") + "-output.json"
+	out, err := os.OpenFile(revision.OutputFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
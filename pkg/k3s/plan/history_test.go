@@ -0,0 +1,141 @@
+package plan
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// addRevisions adds n distinct plans to the history of dataDir
+func addRevisions(t *testing.T, dataDir string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := addRevision(dataDir, []byte(fmt.Sprintf(`{"plan": %d}`, i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestHistoryEmpty(t *testing.T) {
+	dataDir := t.TempDir()
+	history, err := History(dataDir)
+	if err != nil || history != nil {
+		t.Errorf("expected no history, got %v, %v", history, err)
+	}
+	if _, err := GetRevision(dataDir, 0); err == nil {
+		t.Error("expected an error for a revision of an empty history")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dataDir := t.TempDir()
+	addRevisions(t, dataDir, 12)
+
+	history, err := History(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 12 {
+		t.Fatalf("expected 12 revisions, got %d", len(history))
+	}
+	// 10 sorts before 2 by file name
+	for i, revision := range history {
+		if revision.Number != i+1 {
+			t.Errorf("expected revision %d at %d, got %d", i+1, i, revision.Number)
+		}
+		if revision.OutputFile != "" {
+			t.Errorf("expected revision %d without output, got %s", revision.Number, revision.OutputFile)
+		}
+	}
+}
+
+func TestGetRevision(t *testing.T) {
+	dataDir := t.TempDir()
+	addRevisions(t, dataDir, 3)
+
+	tests := []struct {
+		number   int
+		expected int
+		err      bool
+	}{
+		{number: 0, expected: 3},
+		{number: -1, expected: 2},
+		{number: -2, expected: 1},
+		{number: -3, err: true},
+		{number: 1, expected: 1},
+		{number: 3, expected: 3},
+		{number: 4, err: true},
+	}
+	for _, tt := range tests {
+		revision, err := GetRevision(dataDir, tt.number)
+		switch {
+		case tt.err && err == nil:
+			t.Errorf("%d: expected an error, got revision %d", tt.number, revision.Number)
+		case !tt.err && err != nil:
+			t.Errorf("%d: unexpected error %v", tt.number, err)
+		case !tt.err && revision.Number != tt.expected:
+			t.Errorf("%d: expected revision %d, got %d", tt.number, tt.expected, revision.Number)
+		}
+	}
+}
+
+func TestAddRevisionSkipsLatest(t *testing.T) {
+	dataDir := t.TempDir()
+	plan := []byte(`{"plan": "a"}`)
+
+	first, err := addRevision(dataDir, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := addRevision(dataDir, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Number != first.Number || again.File != first.File {
+		t.Errorf("expected the latest revision %d for the same plan, got %d", first.Number, again.Number)
+	}
+
+	if _, err := addRevision(dataDir, []byte(`{"plan": "b"}`)); err != nil {
+		t.Fatal(err)
+	}
+	// a plan that isn't the latest is recorded again, e.g. when rolling back
+	back, err := addRevision(dataDir, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Number != 3 || back.Checksum != first.Checksum {
+		t.Errorf("expected revision 3 with checksum %s, got %d with %s", first.Checksum, back.Number, back.Checksum)
+	}
+}
+
+func TestAddRevisionPrunes(t *testing.T) {
+	dataDir := t.TempDir()
+	addRevisions(t, dataDir, historyLimit)
+	oldest, err := GetRevision(dataDir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(GetPlanOutput(dataDir), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveRevisionOutput(oldest, dataDir); err != nil {
+		t.Fatal(err)
+	}
+
+	addRevisions(t, dataDir, 2)
+	history, err := History(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != historyLimit {
+		t.Fatalf("expected %d revisions, got %d", historyLimit, len(history))
+	}
+	if history[0].Number != 3 || history[len(history)-1].Number != historyLimit+2 {
+		t.Errorf("expected revisions 3 to %d, got %d to %d", historyLimit+2, history[0].Number, history[len(history)-1].Number)
+	}
+	for _, file := range []string{oldest.File, oldest.OutputFile} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", file, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package plan
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/rancher/system-agent/pkg/applyinator"
+	"github.com/rancher/system-agent/pkg/prober"
+)
+
+const defaultFilePermissions = 0600
+
+// RenderedPlan is a human-readable form of an applyinator plan with the file contents decoded
+type RenderedPlan struct {
+	Files                []RenderedFile                    `json:"files,omitempty"`
+	OneTimeInstructions  []applyinator.OneTimeInstruction  `json:"instructions,omitempty"`
+	Probes               map[string]prober.Probe           `json:"probes,omitempty"`
+	PeriodicInstructions []applyinator.PeriodicInstruction `json:"periodicInstructions,omitempty"`
+}
+
+type RenderedFile struct {
+	Path        string `json:"path,omitempty"`
+	Content     string `json:"content,omitempty"`
+	Directory   bool   `json:"directory,omitempty"`
+	UID         int    `json:"uid,omitempty"`
+	GID         int    `json:"gid,omitempty"`
+	Permissions string `json:"permissions,omitempty"`
+}
+
+func Render(plan *applyinator.Plan) (*RenderedPlan, error) {
+	result := &RenderedPlan{
+		OneTimeInstructions:  plan.OneTimeInstructions,
+		Probes:               plan.Probes,
+		PeriodicInstructions: plan.PeriodicInstructions,
+	}
+
+	for _, file := range plan.Files {
+		content, err := base64.StdEncoding.DecodeString(file.Content)
+		if err != nil {
+			return nil, fmt.Errorf("decoding content of %s: %w", file.Path, err)
+		}
+		result.Files = append(result.Files, RenderedFile{
+			Path:        file.Path,
+			Content:     string(content),
+			Directory:   file.Directory,
+			UID:         file.UID,
+			GID:         file.GID,
+			Permissions: file.Permissions,
+		})
+	}
+
+	return result, nil
+}
+
+// WriteFiles writes every file of the plan below stagingDir instead of the root filesystem
+func WriteFiles(plan *applyinator.Plan, stagingDir string) error {
+	for _, file := range plan.Files {
+		path := filepath.Join(stagingDir, file.Path)
+
+		perm := os.FileMode(defaultFilePermissions)
+		if file.Permissions != "" {
+			parsed, err := strconv.ParseUint(file.Permissions, 8, 32)
+			if err != nil {
+				return fmt.Errorf("parsing permissions %s of %s: %w", file.Permissions, file.Path, err)
+			}
+			perm = os.FileMode(parsed)
+		}
+
+		if file.Directory {
+			if err := os.MkdirAll(path, perm|0700); err != nil {
+				return err
+			}
+			continue
+		}
+
+		content, err := base64.StdEncoding.DecodeString(file.Content)
+		if err != nil {
+			return fmt.Errorf("decoding content of %s: %w", file.Path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, content, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -18,18 +20,37 @@ import (
 	"github.com/oneblock-ai/okr/pkg/k3s/versions"
 )
 
+const defaultInstructionAttempts = 5
+
+// Run writes the registries.yaml of cfg and applies the plan
 func Run(ctx context.Context, cfg *config2.Config, plan *applyinator.Plan, dataDir string) error {
 	k8sVersion, err := versions.K8sVersion(cfg.KubernetesVersion)
 	if err != nil {
 		return err
 	}
-	return RunWithKubernetesVersion(ctx, k8sVersion, plan, dataDir)
+	if err := registry.Write(cfg.Registries, config2.GetRuntime(k8sVersion)); err != nil {
+		return err
+	}
+	return RunWithKubernetesVersion(ctx, k8sVersion, plan, dataDir, cfg.RetryPolicy.InstructionAttempts)
 }
 
-func RunWithKubernetesVersion(ctx context.Context, k8sVersion string, plan *applyinator.Plan, dataDir string) error {
+// RunWithKubernetesVersion applies the plan, a failing instruction is run up to
+// instructionAttempts times or 5 times if it is 0
+func RunWithKubernetesVersion(ctx context.Context, k8sVersion string, plan *applyinator.Plan, dataDir string, instructionAttempts int) error {
+	if instructionAttempts == 0 {
+		instructionAttempts = defaultInstructionAttempts
+	}
+
 	runtime := config2.GetRuntime(k8sVersion)
 
-	if err := writePlan(plan, dataDir); err != nil {
+	revision, err := writePlan(plan, dataDir)
+	if err != nil {
+		return err
+	}
+
+	// the last runs of the periodic instructions are kept so that okr agent doesn't reset them
+	existing, err := loadPeriodicOutput(dataDir)
+	if err != nil {
 		return err
 	}
 
@@ -42,14 +63,67 @@ func RunWithKubernetesVersion(ctx context.Context, k8sVersion string, plan *appl
 			Plan: *plan,
 		},
 		RunOneTimeInstructions:     true,
-		OneTimeInstructionAttempts: 5,
+		OneTimeInstructionAttempts: instructionAttempts,
 		ReconcileFiles:             true,
+		ExistingPeriodicOutput:     existing,
+	})
+	// the output of a failed apply is saved too, it shows the instructions that ran
+	if saveErr := saveApplyOutput(applyOutput, revision, dataDir); saveErr != nil {
+		return errors.Join(err, saveErr)
+	}
+	return err
+}
+
+// saveApplyOutput saves the output of the one time instructions to the plan output and the
+// revision, it is left as is if the apply failed before running them
+func saveApplyOutput(applyOutput applyinator.ApplyOutput, revision *Revision, dataDir string) error {
+	if len(applyOutput.OneTimeOutput) > 0 {
+		if err := saveOutput(applyOutput.OneTimeOutput, dataDir); err != nil {
+			return err
+		}
+		if err := saveRevisionOutput(revision, dataDir); err != nil {
+			return err
+		}
+	}
+	return savePeriodicOutput(applyOutput.PeriodicOutput, dataDir)
+}
+
+// RunPeriodic runs the periodic instructions of the plan whose period has elapsed since
+// their last run recorded in the periodic output of dataDir
+func RunPeriodic(ctx context.Context, k8sVersion string, plan *applyinator.Plan, dataDir string) error {
+	if len(plan.PeriodicInstructions) == 0 {
+		return nil
+	}
+	runtime := config2.GetRuntime(k8sVersion)
+
+	existing, err := loadPeriodicOutput(dataDir)
+	if err != nil {
+		return err
+	}
+
+	images := image.NewUtility("", "", "", registry.GetConfigFile(runtime))
+	apply := applyinator.NewApplyinator(filepath.Join(dataDir, "plan", "work"), false,
+		filepath.Join(dataDir, "plan", "applied"), "", images)
+
+	applyOutput, err := apply.Apply(ctx, applyinator.ApplyInput{
+		CalculatedPlan: applyinator.CalculatedPlan{
+			Plan: applyinator.Plan{
+				PeriodicInstructions: plan.PeriodicInstructions,
+			},
+		},
+		ExistingPeriodicOutput: existing,
 	})
 	if err != nil {
 		return err
 	}
 
-	return saveOutput(applyOutput.OneTimeOutput, dataDir)
+	if err := savePeriodicOutput(applyOutput.PeriodicOutput, dataDir); err != nil {
+		return err
+	}
+	if !applyOutput.PeriodicApplySucceeded {
+		return fmt.Errorf("periodic instructions failed, see %s", GetPeriodicOutput(dataDir))
+	}
+	return nil
 }
 
 func saveOutput(data []byte, dataDir string) error {
@@ -68,22 +142,85 @@ func saveOutput(data []byte, dataDir string) error {
 	return err
 }
 
-func writePlan(plan *applyinator.Plan, dataDir string) error {
+// savePeriodicOutput writes the gzipped periodic output of the applyinator as plain json
+func savePeriodicOutput(data []byte, dataDir string) error {
+	if len(data) == 0 {
+		return nil
+	}
+	in, err := gzip.NewReader(bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	decoded, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(GetPeriodicOutput(dataDir), decoded, 0600)
+}
+
+// loadPeriodicOutput returns the periodic output gzipped the way the applyinator expects it
+func loadPeriodicOutput(dataDir string) ([]byte, error) {
+	data, err := os.ReadFile(GetPeriodicOutput(dataDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	out := gzip.NewWriter(&buf)
+	if _, err := out.Write(data); err != nil {
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Load reads the last applied plan of dataDir, it returns nil if there is none
+func Load(dataDir string) (*applyinator.Plan, error) {
+	data, err := os.ReadFile(GetPlanFile(dataDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	plan := &applyinator.Plan{}
+	if err := json.Unmarshal(data, plan); err != nil {
+		return nil, fmt.Errorf("parsing plan %s: %w", GetPlanFile(dataDir), err)
+	}
+	return plan, nil
+}
+
+// writePlan writes the plan file and records it in the plan history
+func writePlan(plan *applyinator.Plan, dataDir string) (*Revision, error) {
 	planFile := GetPlanFile(dataDir)
 	if err := os.MkdirAll(filepath.Dir(planFile), 0755); err != nil {
-		return err
+		return nil, err
 	}
 
-	logrus.Infof("Writing plan file to %s", planFile)
-	f, err := os.OpenFile(planFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	data, err := encode(plan)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer f.Close()
 
-	enc := json.NewEncoder(f)
+	logrus.Infof("Writing plan file to %s", planFile)
+	if err := os.WriteFile(planFile, data, 0600); err != nil {
+		return nil, err
+	}
+	return addRevision(dataDir, data)
+}
+
+func encode(plan *applyinator.Plan) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
 	enc.SetIndent("", "  ")
-	return enc.Encode(plan)
+	if err := enc.Encode(plan); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 func GetPlanFile(dataDir string) string {
@@ -93,3 +230,7 @@ func GetPlanFile(dataDir string) string {
 func GetPlanOutput(dataDir string) string {
 	return filepath.Join(dataDir, "plan", "plan-output.json")
 }
+
+func GetPeriodicOutput(dataDir string) string {
+	return filepath.Join(dataDir, "plan", "periodic-output.json")
+}
@@ -0,0 +1,157 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rancher/system-agent/pkg/applyinator"
+)
+
+const k8sVersion = "v1.28.4+k3s2"
+
+// countingPlan returns a plan whose periodic instruction appends a line to the returned file
+// on every run
+func countingPlan(t *testing.T) (*applyinator.Plan, string) {
+	t.Helper()
+	runs := filepath.Join(t.TempDir(), "runs")
+	return &applyinator.Plan{
+		PeriodicInstructions: []applyinator.PeriodicInstruction{
+			{
+				CommonInstruction: applyinator.CommonInstruction{
+					Name:    "count",
+					Command: "/bin/sh",
+					Args:    []string{"-c", "echo run >> " + runs},
+				},
+				PeriodSeconds: 3600,
+			},
+		},
+	}, runs
+}
+
+func failingPlan() *applyinator.Plan {
+	return &applyinator.Plan{
+		PeriodicInstructions: []applyinator.PeriodicInstruction{
+			{
+				CommonInstruction: applyinator.CommonInstruction{
+					Name:    "fail",
+					Command: "/bin/sh",
+					Args:    []string{"-c", "exit 1"},
+				},
+			},
+		},
+	}
+}
+
+func countRuns(t *testing.T, runs string) int {
+	t.Helper()
+	data, err := os.ReadFile(runs)
+	if os.IsNotExist(err) {
+		return 0
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "run\n")
+}
+
+func TestRunPeriodic(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dataDir, "plan"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	plan, runs := countingPlan(t)
+
+	if err := RunPeriodic(context.Background(), k8sVersion, plan, dataDir); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRuns(t, runs); n != 1 {
+		t.Fatalf("expected the instruction to run once, ran %d times", n)
+	}
+	if _, err := os.Stat(GetPeriodicOutput(dataDir)); err != nil {
+		t.Fatalf("expected the periodic output to be saved: %v", err)
+	}
+
+	if err := RunPeriodic(context.Background(), k8sVersion, plan, dataDir); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRuns(t, runs); n != 1 {
+		t.Errorf("expected the instruction not to run again before its period elapsed, ran %d times", n)
+	}
+}
+
+func TestRunPeriodicFailure(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dataDir, "plan"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunPeriodic(context.Background(), k8sVersion, failingPlan(), dataDir); err == nil {
+		t.Error("expected an error for a failing periodic instruction")
+	}
+}
+
+func TestRunKeepsPeriodicOutput(t *testing.T) {
+	dataDir := t.TempDir()
+	plan := failingPlan()
+
+	for i := 0; i < 2; i++ {
+		if err := RunWithKubernetesVersion(context.Background(), k8sVersion, plan, dataDir, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(GetPeriodicOutput(dataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := map[string]applyinator.PeriodicInstructionOutput{}
+	if err := json.Unmarshal(data, &outputs); err != nil {
+		t.Fatal(err)
+	}
+	if failures := outputs["fail"].Failures; failures != 2 {
+		t.Errorf("expected applying the plan again to keep counting the failures, got %d", failures)
+	}
+}
+
+func TestRunSavesOutputOnFailure(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dataDir, "plan"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the applyinator fails on the periodic output after running the one time instructions
+	if err := os.WriteFile(GetPeriodicOutput(dataDir), []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	plan := &applyinator.Plan{
+		OneTimeInstructions: []applyinator.OneTimeInstruction{
+			{
+				CommonInstruction: applyinator.CommonInstruction{
+					Name:    "hello",
+					Command: "/bin/sh",
+					Args:    []string{"-c", "echo hello"},
+				},
+				SaveOutput: true,
+			},
+		},
+	}
+
+	if err := RunWithKubernetesVersion(context.Background(), k8sVersion, plan, dataDir, 1); err == nil {
+		t.Fatal("expected the apply to fail")
+	}
+	revision, err := GetRevision(dataDir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revision.OutputFile == "" {
+		t.Fatal("expected the output of the failed apply to be saved to the revision")
+	}
+	data, err := os.ReadFile(revision.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"hello"`) {
+		t.Errorf("expected the output of the instruction, got %s", data)
+	}
+}
@@ -1,32 +1,78 @@
 package registry
 
 import (
-	"encoding/base64"
+	"bytes"
 	"fmt"
+	"os"
+	"path/filepath"
 
-	"github.com/rancher/system-agent/pkg/applyinator"
-	"github.com/rancher/wharfie/pkg/registries"
-	"sigs.k8s.io/yaml"
+	"gopkg.in/yaml.v3"
 
 	"github.com/oneblock-ai/okr/pkg/k3s/config"
 )
 
-func ToFile(registry *registries.Registry, runtime config.Runtime) (*applyinator.File, error) {
+// Write writes the registries.yaml of the runtime with the credentials read from their file
+// or environment variable. It isn't a file of the plan so that the credentials never end up
+// in the plan file, its history or the rendered plan. Without registries an existing file,
+// e.g. one written by other tools, is left untouched.
+func Write(registry *config.Registry, runtime config.Runtime) error {
 	if registry == nil {
-		return nil, nil
+		return nil
 	}
+	return writeFile(registry, GetConfigFile(runtime))
+}
+
+// Remove removes the registries.yaml of the runtime, if any
+func Remove(runtime config.Runtime) error {
+	return removeFile(GetConfigFile(runtime))
+}
 
-	data, err := yaml.Marshal(registry)
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing %s: %w", path, err)
+	}
+	return nil
+}
+
+func writeFile(registry *config.Registry, path string) error {
+	data, err := Render(registry)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
 	}
+	// the file is only readable by root as it holds the credentials, it is replaced at once
+	// so that the runtime never reads a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0400); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
 
-	return &applyinator.File{
-		Content:     base64.StdEncoding.EncodeToString(data),
-		Path:        GetConfigFile(runtime),
-		Permissions: "0400",
-	}, nil
+// Render returns the registries.yaml with the credentials resolved
+func Render(registry *config.Registry) ([]byte, error) {
+	resolved, err := registry.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("registries: %w", err)
+	}
 
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(resolved); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 func GetConfigFile(runtime config.Runtime) string {
@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	registry := &config.Registry{
+		Mirrors: map[string]config.Mirror{"docker.io": {Endpoints: []string{"https://mirror.example.com"}}},
+		Configs: map[string]config.RegistryConfig{"mirror.example.com": {
+			Auth: &config.AuthConfig{Username: "okr", PasswordFile: passwordFile},
+		}},
+	}
+	path := filepath.Join(dir, "rancher", "k3s", "registries.yaml")
+
+	// the file is replaced on every apply
+	for i := 0; i < 2; i++ {
+		if err := writeFile(registry, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0400 {
+		t.Errorf("expected mode 0400, got %o", mode)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `mirrors:
+  docker.io:
+    endpoint:
+      - https://mirror.example.com
+configs:
+  mirror.example.com:
+    auth:
+      username: okr
+      password: from-file
+`
+	if string(data) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, data)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected no temporary file, got %v", err)
+	}
+}
+
+func TestWriteFileInvalidCredentials(t *testing.T) {
+	registry := &config.Registry{
+		Auths: map[string]config.AuthConfig{"registry.example.com": {PasswordEnv: "OKR_TEST_MISSING"}},
+	}
+	path := filepath.Join(t.TempDir(), "registries.yaml")
+	if err := writeFile(registry, path); err == nil || !strings.Contains(err.Error(), "OKR_TEST_MISSING") {
+		t.Errorf("expected an error about the missing variable, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected no file to be written, got %v", err)
+	}
+}
+
+func TestWriteWithoutRegistries(t *testing.T) {
+	if err := Write(nil, config.RuntimeK3S); err != nil {
+		t.Errorf("expected nothing to be written, got %v", err)
+	}
+}
+
+func TestRemoveFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registries.yaml")
+	if err := os.WriteFile(path, []byte("mirrors: {}\n"), 0400); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the file to be removed, got %v", err)
+	}
+	if err := removeFile(path); err != nil {
+		t.Errorf("expected removing a missing file to succeed, got %v", err)
+	}
+}
@@ -3,31 +3,24 @@ package retry
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"os"
 	"os/exec"
-	"time"
-
-	"github.com/sirupsen/logrus"
+	"strings"
 )
 
-func Retry(ctx context.Context, interval time.Duration, args []string) error {
-	for {
+// Retry runs the command until it succeeds or the policy gives up
+func Retry(ctx context.Context, policy Policy, args []string) error {
+	return policy.Do(ctx, fmt.Sprintf("command %v", args), func(ctx context.Context) error {
 		var stderr bytes.Buffer
-		cmd := exec.Command(args[0], args[1:]...)
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 		cmd.Stdout = os.Stdout
 		cmd.Stdin = os.Stdin
-		cmd.Stderr = os.Stderr
 		cmd.Stderr = &stderr
 		err := cmd.Run()
-		if err != nil {
-			logrus.Errorf("will retry failed command %v: %v, %v", args, err.Error(), stderr.String())
-			select {
-			case <-time.After(interval):
-				continue
-			case <-ctx.Done():
-				return ctx.Err()
-			}
+		if err != nil && stderr.Len() > 0 {
+			return fmt.Errorf("%w, %s", err, strings.TrimSpace(stderr.String()))
 		}
-		return nil
-	}
+		return err
+	})
 }
@@ -0,0 +1,147 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/utils/pointer"
+)
+
+const (
+	DefaultInitialInterval = 5 * time.Second
+	DefaultMaxInterval     = 2 * time.Minute
+	DefaultMultiplier      = 2
+	DefaultJitter          = 0.2
+)
+
+// Policy is an exponential backoff with jitter. A zero interval and a nil Multiplier or Jitter
+// select the default, an explicit zero Jitter disables the jitter. A zero MaxAttempts,
+// AttemptTimeout or Timeout is unlimited
+type Policy struct {
+	// InitialInterval is the wait after the first failed attempt
+	InitialInterval time.Duration
+	// MaxInterval caps the wait between two attempts
+	MaxInterval time.Duration
+	// Multiplier grows the wait after every failed attempt
+	Multiplier *float64
+	// Jitter randomizes the wait by up to this fraction in both directions
+	Jitter *float64
+	// MaxAttempts is the number of attempts before giving up
+	MaxAttempts int
+	// AttemptTimeout bounds a single attempt
+	AttemptTimeout time.Duration
+	// Timeout bounds all attempts including the waits between them
+	Timeout time.Duration
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.InitialInterval == 0 {
+		p.InitialInterval = DefaultInitialInterval
+	}
+	if p.MaxInterval == 0 {
+		p.MaxInterval = DefaultMaxInterval
+	}
+	if p.Multiplier == nil {
+		p.Multiplier = pointer.Float64(DefaultMultiplier)
+	}
+	if p.Jitter == nil {
+		p.Jitter = pointer.Float64(DefaultJitter)
+	}
+	return p
+}
+
+// Validate checks that no field is negative and that the jitter is a fraction
+func (p Policy) Validate() error {
+	switch {
+	case p.InitialInterval < 0 || p.MaxInterval < 0 || p.AttemptTimeout < 0 || p.Timeout < 0:
+		return errors.New("intervals and timeouts must not be negative")
+	case p.Multiplier != nil && *p.Multiplier < 1:
+		return errors.New("multiplier must be at least 1")
+	case p.Jitter != nil && (*p.Jitter < 0 || *p.Jitter >= 1):
+		return errors.New("jitter must be between 0 and 1")
+	case p.MaxAttempts < 0:
+		return errors.New("maxAttempts must not be negative")
+	}
+	return nil
+}
+
+// Backoff returns the wait after the given number of failed attempts
+func (p Policy) Backoff(attempt int) time.Duration {
+	p = p.withDefaults()
+	wait := float64(p.InitialInterval) * math.Pow(*p.Multiplier, float64(attempt-1))
+	wait = math.Min(wait, float64(p.MaxInterval))
+	wait += wait * *p.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(wait)
+}
+
+// Args returns the okr retry flags that select this policy
+func (p Policy) Args() (result []string) {
+	if p.InitialInterval != 0 {
+		result = append(result, "--initial-interval", p.InitialInterval.String())
+	}
+	if p.MaxInterval != 0 {
+		result = append(result, "--max-interval", p.MaxInterval.String())
+	}
+	if p.Multiplier != nil {
+		result = append(result, "--multiplier", strconv.FormatFloat(*p.Multiplier, 'f', -1, 64))
+	}
+	if p.Jitter != nil {
+		result = append(result, "--jitter", strconv.FormatFloat(*p.Jitter, 'f', -1, 64))
+	}
+	if p.MaxAttempts != 0 {
+		result = append(result, "--max-attempts", strconv.Itoa(p.MaxAttempts))
+	}
+	if p.AttemptTimeout != 0 {
+		result = append(result, "--attempt-timeout", p.AttemptTimeout.String())
+	}
+	if p.Timeout != 0 {
+		result = append(result, "--timeout", p.Timeout.String())
+	}
+	return result
+}
+
+// Do calls f until it succeeds or the policy gives up, what describes f in the logs
+func (p Policy) Do(ctx context.Context, what string, f func(ctx context.Context) error) error {
+	p = p.withDefaults()
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := p.attempt(ctx, f)
+		if err == nil {
+			return nil
+		}
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		wait := p.Backoff(attempt)
+		logrus.Errorf("%s failed, will retry in %s: %v", what, wait.Round(time.Millisecond), err)
+		select {
+		case <-ctx.Done():
+			if p.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("giving up after %s: %w", p.Timeout, err)
+			}
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (p Policy) attempt(ctx context.Context, f func(ctx context.Context) error) error {
+	if p.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.AttemptTimeout)
+		defer cancel()
+	}
+	return f(ctx)
+}
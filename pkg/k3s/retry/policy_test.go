@@ -0,0 +1,187 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/utils/pointer"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := Policy{
+		InitialInterval: time.Second,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      pointer.Float64(3),
+		Jitter:          pointer.Float64(0),
+	}
+	for attempt, expected := range map[int]time.Duration{
+		1: time.Second,
+		2: 3 * time.Second,
+		3: 9 * time.Second,
+		4: 10 * time.Second,
+	} {
+		if wait := policy.Backoff(attempt); wait != expected {
+			t.Errorf("attempt %d: expected %s, got %s", attempt, expected, wait)
+		}
+	}
+}
+
+func TestBackoffDefaults(t *testing.T) {
+	lower := time.Duration(float64(DefaultInitialInterval) * (1 - DefaultJitter))
+	upper := time.Duration(float64(DefaultInitialInterval) * (1 + DefaultJitter))
+	for i := 0; i < 100; i++ {
+		if wait := (Policy{}).Backoff(1); wait < lower || wait > upper {
+			t.Fatalf("expected the first wait between %s and %s, got %s", lower, upper, wait)
+		}
+	}
+	if wait := (Policy{}).Backoff(100); wait > time.Duration(float64(DefaultMaxInterval)*(1+DefaultJitter)) {
+		t.Errorf("expected the wait to be capped by the default max interval, got %s", wait)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		err    string
+	}{
+		{name: "defaults"},
+		{name: "jitter disabled", policy: Policy{Jitter: pointer.Float64(0)}},
+		{name: "constant wait", policy: Policy{Multiplier: pointer.Float64(1)}},
+		{name: "zero multiplier", policy: Policy{Multiplier: pointer.Float64(0)}, err: "multiplier"},
+		{name: "shrinking multiplier", policy: Policy{Multiplier: pointer.Float64(0.5)}, err: "multiplier"},
+		{name: "jitter too large", policy: Policy{Jitter: pointer.Float64(1)}, err: "jitter"},
+		{name: "negative jitter", policy: Policy{Jitter: pointer.Float64(-0.1)}, err: "jitter"},
+		{name: "negative timeout", policy: Policy{Timeout: -time.Second}, err: "negative"},
+		{name: "negative attempts", policy: Policy{MaxAttempts: -1}, err: "maxAttempts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("expected no error, got %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("expected an error about %s, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		expected []string
+	}{
+		{name: "defaults"},
+		{
+			name:     "explicit zero jitter",
+			policy:   Policy{Jitter: pointer.Float64(0)},
+			expected: []string{"--jitter", "0"},
+		},
+		{
+			name: "all settings",
+			policy: Policy{
+				InitialInterval: time.Second,
+				MaxInterval:     time.Minute,
+				Multiplier:      pointer.Float64(1.5),
+				Jitter:          pointer.Float64(0.1),
+				MaxAttempts:     3,
+				AttemptTimeout:  10 * time.Second,
+				Timeout:         time.Hour,
+			},
+			expected: []string{
+				"--initial-interval", "1s",
+				"--max-interval", "1m0s",
+				"--multiplier", "1.5",
+				"--jitter", "0.1",
+				"--max-attempts", "3",
+				"--attempt-timeout", "10s",
+				"--timeout", "1h0m0s",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if args := tt.policy.Args(); !reflect.DeepEqual(args, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, args)
+			}
+		})
+	}
+}
+
+func TestDo(t *testing.T) {
+	fast := Policy{
+		InitialInterval: time.Millisecond,
+		MaxInterval:     time.Millisecond,
+		Jitter:          pointer.Float64(0),
+	}
+	failing := errors.New("failing")
+
+	t.Run("succeeds after failures", func(t *testing.T) {
+		attempts := 0
+		err := fast.Do(context.Background(), "test", func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return failing
+			}
+			return nil
+		})
+		if err != nil || attempts != 3 {
+			t.Errorf("expected success after 3 attempts, got %v after %d", err, attempts)
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		policy := fast
+		policy.MaxAttempts = 2
+		attempts := 0
+		err := policy.Do(context.Background(), "test", func(ctx context.Context) error {
+			attempts++
+			return failing
+		})
+		if !errors.Is(err, failing) || attempts != 2 {
+			t.Errorf("expected to give up after 2 attempts, got %v after %d", err, attempts)
+		}
+	})
+
+	t.Run("gives up after timeout", func(t *testing.T) {
+		policy := fast
+		policy.Timeout = 20 * time.Millisecond
+		err := policy.Do(context.Background(), "test", func(ctx context.Context) error {
+			return failing
+		})
+		if !errors.Is(err, failing) || !strings.Contains(err.Error(), "giving up after") {
+			t.Errorf("expected to give up after the timeout, got %v", err)
+		}
+	})
+
+	t.Run("bounds an attempt", func(t *testing.T) {
+		policy := fast
+		policy.MaxAttempts = 1
+		policy.AttemptTimeout = 10 * time.Millisecond
+		err := policy.Do(context.Background(), "test", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the attempt to time out, got %v", err)
+		}
+	})
+
+	t.Run("stops when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := fast.Do(ctx, "test", func(ctx context.Context) error {
+			cancel()
+			return failing
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the cancellation error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package versions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	DefaultCacheTTL = 24 * time.Hour
+	cacheFile       = "channels.json"
+)
+
+var options = Options{
+	CacheTTL: DefaultCacheTTL,
+}
+
+// Options configures how channel names are resolved to versions
+type Options struct {
+	// ChannelServer replaces the k3s or RKE2 channel server, the channel name is appended to it
+	ChannelServer string
+	// ChannelsFile resolves channels from a local file, the channel server is never contacted
+	ChannelsFile string
+	// CacheDir persists resolved channels, no cache is kept if empty
+	CacheDir string
+	// CacheTTL is how long a cached channel is used before it is resolved again
+	CacheTTL time.Duration
+}
+
+// Configure sets the options used by K8sVersion and drops the versions resolved so far
+func Configure(opts Options) {
+	cachedLock.Lock()
+	defer cachedLock.Unlock()
+
+	if opts.CacheTTL == 0 {
+		opts.CacheTTL = DefaultCacheTTL
+	}
+	options = opts
+	cachedK8sVersion = map[string]string{}
+}
+
+// Channels is the format of the local channels file, it is the same as the channels
+// collection served by the channel server, e.g. https://update.k3s.io/v1-release/channels,
+// so a copy of it can be used as is
+type Channels struct {
+	Data []Channel `json:"data,omitempty"`
+}
+
+type Channel struct {
+	Name   string `json:"name,omitempty"`
+	Latest string `json:"latest,omitempty"`
+}
+
+func fromChannelsFile(file, channel string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("reading channels file: %w", err)
+	}
+
+	channels := Channels{}
+	if err := yaml.Unmarshal(data, &channels); err != nil {
+		return "", fmt.Errorf("parsing channels file %s: %w", file, err)
+	}
+
+	for _, c := range channels.Data {
+		if c.Name == channel && c.Latest != "" {
+			return c.Latest, nil
+		}
+	}
+	return "", fmt.Errorf("channel %s not found in channels file %s", channel, file)
+}
+
+type cacheEntry struct {
+	Version    string    `json:"version"`
+	ResolvedAt time.Time `json:"resolvedAt"`
+}
+
+// loadCache returns the cached channels keyed by channel URL, a missing or corrupt cache is empty
+func loadCache(dir string) map[string]cacheEntry {
+	cache := map[string]cacheEntry{}
+	if dir == "" {
+		return cache
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, cacheFile))
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return map[string]cacheEntry{}
+	}
+	return cache
+}
+
+func saveCache(dir string, cache map[string]cacheEntry) error {
+	if dir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, cacheFile), data, 0600)
+}
@@ -3,14 +3,24 @@ package versions
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"path"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+const (
+	k3sChannelServer  = "https://update.k3s.io/v1-release/channels/%s"
+	rke2ChannelServer = "https://update.rke2.io/v1-release/channels/%s"
 )
 
 var (
+	channelName      = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]*$`)
 	cachedK8sVersion = map[string]string{}
 	cachedLock       sync.Mutex
 	redirectClient   = &http.Client{
@@ -41,6 +51,8 @@ func getVersionOrURL(urlFormat, def, version string) (_ string, isURL bool) {
 	return channelURL, true
 }
 
+// K8sVersion resolves kubernetesVersion to an exact version. Channel names are resolved
+// from the channels file if configured, the on-disk cache or the channel server
 func K8sVersion(kubernetesVersion string) (string, error) {
 	cachedLock.Lock()
 	defer cachedLock.Unlock()
@@ -50,29 +62,112 @@ func K8sVersion(kubernetesVersion string) (string, error) {
 		return cached, nil
 	}
 
-	urlFormat := "https://update.k3s.io/v1-release/channels/%s"
-	if strings.HasSuffix(kubernetesVersion, ":k3s") {
-		kubernetesVersion = strings.TrimSuffix(kubernetesVersion, ":k3s")
+	urlFormat, channel := channelServer(kubernetesVersion)
+	if options.ChannelServer != "" {
+		urlFormat = strings.TrimSuffix(options.ChannelServer, "/") + "/%s"
 	}
-
-	versionOrURL, isURL := getVersionOrURL(urlFormat, "stable", kubernetesVersion)
+	versionOrURL, isURL := getVersionOrURL(urlFormat, "stable", channel)
 	if !isURL {
 		return versionOrURL, nil
 	}
+	if channel == "" {
+		channel = "stable"
+	}
 
-	resp, err := redirectClient.Get(versionOrURL)
+	resolved, source, err := resolveChannel(channel, versionOrURL)
 	if err != nil {
-		return "", fmt.Errorf("getting channel version from (%s): %w", versionOrURL, err)
+		return "", err
+	}
+
+	cachedK8sVersion[kubernetesVersion] = resolved
+	logrus.Infof("Resolving Kubernetes version [%s] to %s from %s", kubernetesVersion, resolved, source)
+	return resolved, nil
+}
+
+// resolveChannel looks up channel in the channels file, the on-disk cache and at last
+// channelURL. A stale cache entry is used if the channel server can not be reached
+func resolveChannel(channel, channelURL string) (resolved, source string, err error) {
+	if options.ChannelsFile != "" {
+		resolved, err := fromChannelsFile(options.ChannelsFile, channel)
+		return resolved, options.ChannelsFile, err
+	}
+
+	cache := loadCache(options.CacheDir)
+	entry, cached := cache[channelURL]
+	if cached && time.Since(entry.ResolvedAt) < options.CacheTTL {
+		return entry.Version, "cache", nil
+	}
+
+	resolved, err = fromChannelServer(channelURL)
+	if err != nil {
+		if cached {
+			logrus.Warnf("Using cached version %s of channel %s resolved at %s: %v",
+				entry.Version, channel, entry.ResolvedAt.Format(time.RFC3339), err)
+			return entry.Version, "cache", nil
+		}
+		return "", "", fmt.Errorf("resolving channel %s from channel server %s: %w", channel, channelURL, err)
+	}
+
+	cache[channelURL] = cacheEntry{
+		Version:    resolved,
+		ResolvedAt: time.Now(),
+	}
+	if err := saveCache(options.CacheDir, cache); err != nil {
+		logrus.Warnf("Failed to save channel cache in %s: %v", options.CacheDir, err)
+	}
+	return resolved, channelURL, nil
+}
+
+func fromChannelServer(channelURL string) (string, error) {
+	resp, err := redirectClient.Get(channelURL)
+	if err != nil {
+		return "", err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return "", fmt.Errorf("expected a redirect to the channel version, got %s", resp.Status)
+	}
+
 	url, err := resp.Location()
 	if err != nil {
-		return "", fmt.Errorf("getting channel version URL from (%s): %w", versionOrURL, err)
+		return "", fmt.Errorf("getting channel version URL: %w", err)
 	}
+	return path.Base(url.Path), nil
+}
 
-	resolved := path.Base(url.Path)
-	cachedK8sVersion[kubernetesVersion] = resolved
-	logrus.Infof("Resolving Kubernetes version [%s] to %s from %s ", kubernetesVersion, resolved, versionOrURL)
-	return resolved, nil
+// channelServer returns the channel server URL format for the runtime selected by the
+// optional :k3s or :rke2 suffix of kubernetesVersion, k3s is the default
+func channelServer(kubernetesVersion string) (urlFormat, channel string) {
+	if strings.HasSuffix(kubernetesVersion, ":rke2") {
+		return rke2ChannelServer, strings.TrimSuffix(kubernetesVersion, ":rke2")
+	}
+	return k3sChannelServer, strings.TrimSuffix(kubernetesVersion, ":k3s")
+}
+
+// Validate checks that kubernetesVersion is either a well-formed version, a channel name or a channel URL
+func Validate(kubernetesVersion string) error {
+	_, kubernetesVersion = channelServer(kubernetesVersion)
+	if kubernetesVersion == "" {
+		return nil
+	}
+
+	versionOrURL, isURL := getVersionOrURL("%s", "stable", kubernetesVersion)
+	switch {
+	case !isURL:
+		if strings.HasPrefix(versionOrURL, "v") && len(strings.Split(versionOrURL, ".")) > 2 {
+			if _, err := version.ParseSemantic(versionOrURL); err != nil {
+				return fmt.Errorf("invalid version %q: %w", versionOrURL, err)
+			}
+		}
+	case strings.HasPrefix(versionOrURL, "https://") || strings.HasPrefix(versionOrURL, "http://"):
+		if _, err := url.ParseRequestURI(versionOrURL); err != nil {
+			return fmt.Errorf("invalid channel URL %q: %w", versionOrURL, err)
+		}
+	default:
+		if !channelName.MatchString(versionOrURL) {
+			return fmt.Errorf("invalid channel name %q", versionOrURL)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,158 @@
+package versions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func channelServerStub(t *testing.T, channels map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, ok := channels[strings.TrimPrefix(r.URL.Path, "/v1-release/channels/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, "https://github.com/k3s-io/k3s/releases/tag/"+version, http.StatusFound)
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { Configure(Options{}) })
+	return server
+}
+
+func TestK8sVersionFromChannelServer(t *testing.T) {
+	server := channelServerStub(t, map[string]string{"stable": "v1.28.5+k3s1"})
+	Configure(Options{ChannelServer: server.URL + "/v1-release/channels"})
+
+	for _, input := range []string{"", "stable", "stable:k3s"} {
+		version, err := K8sVersion(input)
+		if err != nil {
+			t.Fatalf("resolving %q: %v", input, err)
+		}
+		if version != "v1.28.5+k3s1" {
+			t.Errorf("resolving %q: expected v1.28.5+k3s1, got %s", input, version)
+		}
+	}
+}
+
+func TestK8sVersionPinned(t *testing.T) {
+	Configure(Options{ChannelServer: "http://127.0.0.1:0"})
+	t.Cleanup(func() { Configure(Options{}) })
+
+	version, err := K8sVersion("v1.28.4+rke2r1:rke2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "v1.28.4+rke2r1" {
+		t.Errorf("expected v1.28.4+rke2r1, got %s", version)
+	}
+}
+
+func TestK8sVersionCache(t *testing.T) {
+	channels := map[string]string{"stable": "v1.28.5+k3s1"}
+	server := channelServerStub(t, channels)
+	opts := Options{
+		ChannelServer: server.URL + "/v1-release/channels",
+		CacheDir:      t.TempDir(),
+		CacheTTL:      time.Hour,
+	}
+	Configure(opts)
+
+	if _, err := K8sVersion("stable"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a fresh cache entry is used without asking the channel server
+	channels["stable"] = "v1.29.0+k3s1"
+	Configure(opts)
+	version, err := K8sVersion("stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "v1.28.5+k3s1" {
+		t.Errorf("expected cached v1.28.5+k3s1, got %s", version)
+	}
+
+	// an expired entry is resolved again
+	opts.CacheTTL = time.Nanosecond
+	Configure(opts)
+	version, err = K8sVersion("stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "v1.29.0+k3s1" {
+		t.Errorf("expected v1.29.0+k3s1, got %s", version)
+	}
+
+	// an expired entry is still used when the channel server is unreachable
+	server.Close()
+	Configure(opts)
+	version, err = K8sVersion("stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "v1.29.0+k3s1" {
+		t.Errorf("expected stale v1.29.0+k3s1, got %s", version)
+	}
+}
+
+func TestK8sVersionError(t *testing.T) {
+	server := channelServerStub(t, map[string]string{})
+	Configure(Options{ChannelServer: server.URL + "/v1-release/channels"})
+
+	_, err := K8sVersion("testing")
+	if err == nil {
+		t.Fatal("expected an error for an unknown channel")
+	}
+	for _, expected := range []string{"testing", server.URL} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q to contain %q", err, expected)
+		}
+	}
+}
+
+func TestK8sVersionChannelsFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "channels.json")
+	data := `{"type":"collection","data":[{"id":"stable","name":"stable","latest":"v1.28.5+k3s1"},{"id":"latest","name":"latest","latest":"v1.29.0+k3s1"}]}`
+	if err := os.WriteFile(file, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	Configure(Options{ChannelsFile: file, ChannelServer: "http://127.0.0.1:0"})
+	t.Cleanup(func() { Configure(Options{}) })
+
+	version, err := K8sVersion("latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "v1.29.0+k3s1" {
+		t.Errorf("expected v1.29.0+k3s1, got %s", version)
+	}
+
+	if _, err := K8sVersion("v1.27"); err == nil || !strings.Contains(err.Error(), file) {
+		t.Errorf("expected a channel not found error naming %s, got %v", file, err)
+	}
+}
+
+func TestChannelServer(t *testing.T) {
+	tests := []struct {
+		input     string
+		urlFormat string
+		channel   string
+	}{
+		{input: "", urlFormat: k3sChannelServer, channel: ""},
+		{input: "stable", urlFormat: k3sChannelServer, channel: "stable"},
+		{input: "v1.28:k3s", urlFormat: k3sChannelServer, channel: "v1.28"},
+		{input: "stable:rke2", urlFormat: rke2ChannelServer, channel: "stable"},
+	}
+	for _, tt := range tests {
+		urlFormat, channel := channelServer(tt.input)
+		if urlFormat != tt.urlFormat || channel != tt.channel {
+			t.Errorf("%q: expected %s %q, got %s %q", tt.input, tt.urlFormat, tt.channel, urlFormat, channel)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package okr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	plan2 "github.com/oneblock-ai/okr/pkg/k3s/plan"
+	"github.com/oneblock-ai/okr/pkg/k3s/registry"
+	"github.com/oneblock-ai/okr/pkg/k3s/versions"
+)
+
+// watchDebounce groups the events of an editor saving or a tool writing several files
+const watchDebounce = 2 * time.Second
+
+type AgentConfig struct {
+	// Interval is how often the periodic instructions are checked for an elapsed period
+	Interval time.Duration
+	// Watch re-applies the plan when a change of the config files changes it
+	Watch bool
+}
+
+// Agent runs the periodic instructions of the applied plan until ctx is cancelled and
+// optionally reconciles the node with the config files as they change
+func (o *OKR) Agent(ctx context.Context, agent AgentConfig) error {
+	var (
+		events  <-chan fsnotify.Event
+		errs    <-chan error
+		rewatch func()
+	)
+	if agent.Watch {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("watching config: %w", err)
+		}
+		defer watcher.Close()
+
+		dirs, isConfig := config.WatchPaths(o.cfg.ConfigPath)
+		// directories that don't exist yet are added once an event shows they were created
+		rewatch = func() {
+			for _, dir := range dirs {
+				if err := watcher.Add(dir); err != nil {
+					logrus.Debugf("Not watching %s: %v", dir, err)
+				}
+			}
+		}
+		rewatch()
+
+		filtered := make(chan fsnotify.Event)
+		go func() {
+			defer close(filtered)
+			for event := range watcher.Events {
+				if !isConfig(event.Name) {
+					continue
+				}
+				// the loop stops reading once ctx is done
+				select {
+				case filtered <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		events, errs = filtered, watcher.Errors
+
+		logrus.Infof("Watching config %s for changes", o.cfg.ConfigPath)
+		if err := o.reconcile(ctx); err != nil {
+			logrus.Errorf("failed to reconcile config: %v", err)
+		}
+	}
+
+	logrus.Infof("Running periodic instructions of %s every %s", plan2.GetPlanFile(o.cfg.DataDir), agent.Interval)
+	if err := o.runPeriodic(ctx); err != nil {
+		logrus.Errorf("failed to run periodic instructions: %v", err)
+	}
+
+	ticker := time.NewTicker(agent.Interval)
+	defer ticker.Stop()
+	debounce := time.NewTimer(watchDebounce)
+	debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := o.runPeriodic(ctx); err != nil {
+				logrus.Errorf("failed to run periodic instructions: %v", err)
+			}
+		case event := <-events:
+			logrus.Debugf("Config changed: %s", event)
+			rewatch()
+			debounce.Reset(watchDebounce)
+		case err := <-errs:
+			logrus.Errorf("failed to watch config: %v", err)
+		case <-debounce.C:
+			if err := o.reconcile(ctx); err != nil {
+				logrus.Errorf("failed to reconcile config: %v", err)
+			}
+		}
+	}
+}
+
+// reconcile re-applies the plan if the config files changed it since the node was
+// bootstrapped or last reconciled, the versions are only changed by okr upgrade
+func (o *OKR) reconcile(ctx context.Context) error {
+	current, err := o.bootstrappedConfig()
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		logrus.Debugf("System is not bootstrapped yet, skipping reconcile")
+		return nil
+	}
+
+	cfg, err := config.Load(o.cfg.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if err := o.configureVersions(&cfg); err != nil {
+		return err
+	}
+	cfg.KubernetesVersion = current.KubernetesVersion
+	cfg.KubeRay.Version = current.KubeRay.Version
+
+	change, desiredChecksum, err := o.planChange(ctx, *current, cfg)
+	if err != nil || change == "" {
+		return err
+	}
+
+	logrus.Info(change)
+	if err := o.setWorking(cfg); err != nil {
+		return fmt.Errorf("saving working config to %s: %w", o.WorkingStamp(), err)
+	}
+	// plan2.Run only writes the registries.yaml, a removed registries config would keep the old one
+	if current.Registries != nil && cfg.Registries == nil {
+		k8sVersion, err := versions.K8sVersion(cfg.KubernetesVersion)
+		if err != nil {
+			return err
+		}
+		if err := registry.Remove(config.GetRuntime(k8sVersion)); err != nil {
+			return err
+		}
+	}
+	if err := o.apply(ctx, cfg); err != nil {
+		return err
+	}
+
+	logrus.Infof("Successfully applied plan %s", desiredChecksum)
+	return nil
+}
+
+// planChange describes how applying desired changes the node bootstrapped with current, it is
+// empty if nothing changes. The checksum of the plan of desired is returned as well.
+func (o *OKR) planChange(ctx context.Context, current, desired config.Config) (string, string, error) {
+	// the plans are compared without the bootstrap instructions which are never re-applied
+	currentChecksum, err := o.planChecksum(ctx, current)
+	if err != nil {
+		return "", "", fmt.Errorf("generating plan of the bootstrapped config: %w", err)
+	}
+	desiredChecksum, err := o.planChecksum(ctx, desired)
+	if err != nil {
+		return "", "", fmt.Errorf("generating plan: %w", err)
+	}
+
+	switch {
+	// the registries.yaml isn't part of the plan as it holds the registry credentials
+	case !reflect.DeepEqual(current.Registries, desired.Registries):
+		return fmt.Sprintf("Config changed the registries, applying plan %s", desiredChecksum), desiredChecksum, nil
+	case currentChecksum != desiredChecksum:
+		return fmt.Sprintf("Config changed the plan from %s to %s, applying it", currentChecksum, desiredChecksum), desiredChecksum, nil
+	}
+	logrus.Debugf("Plan is unchanged (%s)", currentChecksum)
+	return "", desiredChecksum, nil
+}
+
+func (o *OKR) planChecksum(ctx context.Context, cfg config.Config) (string, error) {
+	nodePlan, err := plan2.ToPlan(ctx, withoutBootstrapInstructions(cfg), o.cfg.DataDir)
+	if err != nil {
+		return "", err
+	}
+	return plan2.Checksum(nodePlan)
+}
+
+func (o *OKR) runPeriodic(ctx context.Context) error {
+	cfg, err := o.bootstrappedConfig()
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		logrus.Debugf("System is not bootstrapped yet, skipping periodic instructions")
+		return nil
+	}
+
+	// the plan is read on every run to pick up upgrades
+	plan, err := plan2.Load(o.cfg.DataDir)
+	if err != nil || plan == nil {
+		return err
+	}
+
+	if err := o.configureVersions(cfg); err != nil {
+		return err
+	}
+	k8sVersion, err := versions.K8sVersion(cfg.KubernetesVersion)
+	if err != nil {
+		return err
+	}
+	return plan2.RunPeriodic(ctx, k8sVersion, plan, o.cfg.DataDir)
+}
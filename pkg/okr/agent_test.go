@@ -0,0 +1,133 @@
+package okr
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+)
+
+const agentConfig = "role: cluster-init\nkubernetesVersion: v1.28.4+k3s2\nnodeName: node1\ntoken: secret-token\n"
+
+func newAgentOKR(t *testing.T, content string) *OKR {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return New(Config{
+		DataDir:    t.TempDir(),
+		ConfigPath: path,
+	})
+}
+
+// bootstrap records content as the config the node was bootstrapped with
+func bootstrap(t *testing.T, o *OKR, content string) config.Config {
+	t.Helper()
+	cfg, err := config.Load(newAgentOKR(t, content).cfg.ConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.setDone(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func assertNotApplied(t *testing.T, o *OKR) {
+	t.Helper()
+	if _, err := os.Stat(o.WorkingStamp()); !os.IsNotExist(err) {
+		t.Errorf("expected the plan not to be applied, got %v", err)
+	}
+}
+
+func TestReconcileNotBootstrapped(t *testing.T) {
+	o := newAgentOKR(t, agentConfig)
+	if err := o.reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertNotApplied(t, o)
+}
+
+func TestReconcileUnchanged(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "same config", content: agentConfig},
+		{name: "version changed", content: "role: cluster-init\nkubernetesVersion: v1.29.0+k3s1\nnodeName: node1\ntoken: secret-token\n"},
+		{name: "bootstrap instructions changed", content: agentConfig + "preInstructions:\n- name: pre\n  command: /bin/true\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newAgentOKR(t, tt.content)
+			bootstrap(t, o, agentConfig)
+			if err := o.reconcile(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			assertNotApplied(t, o)
+		})
+	}
+}
+
+func TestPlanChecksum(t *testing.T) {
+	o := newAgentOKR(t, agentConfig)
+	checksum := func(content string) string {
+		t.Helper()
+		cfg, err := config.Load(newAgentOKR(t, content).cfg.ConfigPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum, err := o.planChecksum(context.Background(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+
+	bootstrapped := checksum(agentConfig)
+	if again := checksum(agentConfig); again != bootstrapped {
+		t.Errorf("expected the same checksum for the same config, got %s and %s", bootstrapped, again)
+	}
+	if changed := checksum(agentConfig + "labels:\n- zone=a\n"); changed == bootstrapped {
+		t.Errorf("expected a changed config to change the checksum %s", bootstrapped)
+	}
+}
+
+func TestPlanChange(t *testing.T) {
+	const registries = "registries:\n  mirrors:\n    docker.io:\n      endpoint:\n      - https://mirror.example.com\n"
+	load := func(content string) config.Config {
+		t.Helper()
+		cfg, err := config.Load(newAgentOKR(t, content).cfg.ConfigPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		current string
+		desired string
+		changed bool
+	}{
+		{name: "unchanged", current: agentConfig + registries, desired: agentConfig + registries},
+		{name: "plan changed", current: agentConfig, desired: agentConfig + "labels:\n- zone=a\n", changed: true},
+		{name: "registries added", current: agentConfig, desired: agentConfig + registries, changed: true},
+		{name: "registries removed", current: agentConfig + registries, desired: agentConfig, changed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newAgentOKR(t, tt.desired)
+			change, _, err := o.planChange(context.Background(), load(tt.current), load(tt.desired))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (change != "") != tt.changed {
+				t.Errorf("expected changed %v, got %q", tt.changed, change)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package okr
+
+import (
+	"os"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/kubectl"
+)
+
+func newK8sClient() (kubernetes.Interface, error) {
+	restConfig, err := newRestConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(restConfig)
+}
+
+func newDynamicClient() (dynamic.Interface, error) {
+	restConfig, err := newRestConfig()
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(restConfig)
+}
+
+func newRestConfig() (*rest.Config, error) {
+	kubeConfig, err := kubectl.GetKubeconfig("")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return clientcmd.RESTConfigFromKubeConfig(data)
+}
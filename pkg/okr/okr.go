@@ -5,13 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"time"
 
 	"github.com/sirupsen/logrus"
 	"sigs.k8s.io/yaml"
 
 	"github.com/oneblock-ai/okr/pkg/k3s/config"
 	plan2 "github.com/oneblock-ai/okr/pkg/k3s/plan"
+	"github.com/oneblock-ai/okr/pkg/k3s/retry"
 	"github.com/oneblock-ai/okr/pkg/k3s/versions"
 )
 
@@ -52,18 +52,27 @@ func (o *OKR) Run(ctx context.Context) error {
 		return nil
 	}
 
-	for {
-		err := o.execute(ctx)
-		if err == nil {
-			return nil
-		}
-		logrus.Infof("failed to bootstrap system, will retry: %v", err)
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(15 * time.Second):
-		}
+	policy, err := o.retryPolicy()
+	if err != nil {
+		return err
+	}
+	if err := policy.Do(ctx, "bootstrap", o.execute); err != nil {
+		return fmt.Errorf("failed to bootstrap system: %w", err)
+	}
+	return nil
+}
+
+// retryPolicy returns the retry policy of the config
+func (o *OKR) retryPolicy() (retry.Policy, error) {
+	cfg, err := config.Load(o.cfg.ConfigPath)
+	if err != nil {
+		return retry.Policy{}, fmt.Errorf("loading config: %w", err)
+	}
+	policy, err := cfg.RetryPolicy.Policy()
+	if err != nil {
+		return policy, fmt.Errorf("invalid config: retryPolicy: %w", err)
 	}
+	return policy, nil
 }
 
 func (o *OKR) execute(ctx context.Context) error {
@@ -76,6 +85,10 @@ func (o *OKR) execute(ctx context.Context) error {
 		return fmt.Errorf("saving working config to %s: %w", o.WorkingStamp(), err)
 	}
 
+	if err := o.configureVersions(&cfg); err != nil {
+		return err
+	}
+
 	if cfg.Role == "" {
 		logrus.Warn("No role defined, skipping bootstrap")
 		return nil
@@ -105,6 +118,16 @@ func (o *OKR) execute(ctx context.Context) error {
 	return nil
 }
 
+// configureVersions sets up the channel resolution of the config with the cache in the data dir
+func (o *OKR) configureVersions(cfg *config.Config) error {
+	opts, err := cfg.Channels.VersionOptions(o.cfg.DataDir)
+	if err != nil {
+		return err
+	}
+	versions.Configure(opts)
+	return nil
+}
+
 func (o *OKR) writeConfig(path string, cfg config.Config) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0600); err != nil {
 		return fmt.Errorf("mkdir %s: %w", filepath.Dir(path), err)
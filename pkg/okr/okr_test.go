@@ -0,0 +1,32 @@
+package okr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "default", content: agentConfig},
+		{name: "invalid policy", content: agentConfig + "retryPolicy:\n  instructionAttempts: -1\n", wantErr: "invalid config: retryPolicy"},
+		{name: "invalid config", content: "role: [", wantErr: "loading config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newAgentOKR(t, tt.content).retryPolicy()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package okr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	runtime2 "github.com/oneblock-ai/okr/pkg/k3s/instructions/runtime"
+	plan2 "github.com/oneblock-ai/okr/pkg/k3s/plan"
+	"github.com/oneblock-ai/okr/pkg/k3s/registry"
+	"github.com/oneblock-ai/okr/pkg/k3s/versions"
+)
+
+const (
+	mirrorPodAnnotation = "kubernetes.io/config.mirror"
+	// agentUnitFile is the okr-agent.service written by the installer or the Ignition config
+	agentUnitFile = "/etc/systemd/system/okr-agent.service"
+)
+
+// evictionRetryInterval is how long to wait before retrying an eviction blocked by a pod disruption budget
+var evictionRetryInterval = 5 * time.Second
+
+type ResetConfig struct {
+	// DeleteNode drains the node and removes it from the cluster before uninstalling
+	DeleteNode bool
+	// KeepData preserves the runtime's etcd data across the uninstall
+	KeepData bool
+	// DrainTimeout bounds how long to wait for pods to be evicted
+	DrainTimeout time.Duration
+}
+
+func (o *OKR) Reset(ctx context.Context, reset ResetConfig) error {
+	cfg, err := o.bootstrappedConfig()
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		loaded, err := config.Load(o.cfg.ConfigPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		cfg = &loaded
+	}
+	if err := o.configureVersions(cfg); err != nil {
+		return err
+	}
+
+	k8sVersion, err := versions.K8sVersion(cfg.KubernetesVersion)
+	if err != nil {
+		return err
+	}
+	runtime := config.GetRuntime(k8sVersion)
+
+	// the agent would re-apply the plan while the node is reset
+	if err := stopAgent(); err != nil {
+		return err
+	}
+
+	if reset.DeleteNode {
+		if err := o.deleteNode(ctx, cfg, reset.DrainTimeout); err != nil {
+			return fmt.Errorf("removing node from cluster: %w", err)
+		}
+	}
+
+	if err := uninstallRuntime(runtime, reset.KeepData); err != nil {
+		return err
+	}
+
+	if err := o.removePlanFiles(); err != nil {
+		return err
+	}
+
+	// registries.yaml holds the registry credentials and is not part of the plan, it is left
+	// behind when there is no uninstall script
+	if err := registry.Remove(runtime); err != nil {
+		return err
+	}
+
+	logrus.Infof("Removing okr data dir %s", o.cfg.DataDir)
+	if err := os.RemoveAll(o.cfg.DataDir); err != nil {
+		return fmt.Errorf("removing data dir %s: %w", o.cfg.DataDir, err)
+	}
+
+	logrus.Info("Successfully reset node")
+	return nil
+}
+
+func (o *OKR) deleteNode(ctx context.Context, cfg *config.Config, drainTimeout time.Duration) error {
+	nodeName, err := config.GetNodeName(&cfg.RuntimeConfig)
+	if err != nil {
+		return err
+	}
+
+	k8s, err := newK8sClient()
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Cordoning node %s", nodeName)
+	_, err = k8s.CoreV1().Nodes().Patch(ctx, nodeName, types.MergePatchType,
+		[]byte(`{"spec":{"unschedulable":true}}`), metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		logrus.Infof("Node %s is not registered in the cluster, skipping drain", nodeName)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	drainCtx := ctx
+	if drainTimeout > 0 {
+		var cancel context.CancelFunc
+		drainCtx, cancel = context.WithTimeout(ctx, drainTimeout)
+		defer cancel()
+	}
+	if err := drainNode(drainCtx, k8s, nodeName); err != nil {
+		return fmt.Errorf("draining node %s: %w", nodeName, err)
+	}
+
+	logrus.Infof("Deleting node %s", nodeName)
+	if err := k8s.CoreV1().Nodes().Delete(ctx, nodeName, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func drainNode(ctx context.Context, k8s kubernetes.Interface, nodeName string) error {
+	pods, err := k8s.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: "spec.nodeName=" + nodeName,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range pods.Items {
+		if !isEvictable(&pod) {
+			continue
+		}
+		logrus.Infof("Evicting pod %s/%s", pod.Namespace, pod.Name)
+		if err := evictPod(ctx, k8s, &pod); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isEvictable(pod *corev1.Pod) bool {
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return false
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	return true
+}
+
+func evictPod(ctx context.Context, k8s kubernetes.Interface, pod *corev1.Pod) error {
+	for {
+		err := k8s.CoreV1().Pods(pod.Namespace).EvictV1(ctx, &policyv1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pod.Name,
+				Namespace: pod.Namespace,
+			},
+		})
+		if err == nil || apierrors.IsNotFound(err) {
+			return nil
+		}
+		if !apierrors.IsTooManyRequests(err) {
+			return fmt.Errorf("evicting pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+
+		// a pod disruption budget is blocking the eviction
+		logrus.Infof("Eviction of pod %s/%s is blocked, will retry: %v", pod.Namespace, pod.Name, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(evictionRetryInterval):
+		}
+	}
+}
+
+// stopAgent stops and disables okr-agent.service if it is installed
+func stopAgent() error {
+	if _, err := os.Stat(agentUnitFile); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	logrus.Infof("Stopping and disabling %s", filepath.Base(agentUnitFile))
+	cmd := exec.Command("systemctl", "disable", "--now", filepath.Base(agentUnitFile))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("stopping %s: %w", filepath.Base(agentUnitFile), err)
+	}
+	return nil
+}
+
+// uninstallRuntime runs the uninstall script of the runtime, with keepData the datastore is
+// moved aside during the uninstall and restored afterwards, also when the uninstall fails
+func uninstallRuntime(runtime config.Runtime, keepData bool) error {
+	return uninstall(runtime2.GetDataDir(runtime), runtime2.GetUninstallScripts(runtime), keepData)
+}
+
+// uninstall runs the first of scripts that exists, keeping the datastore below dataDir with keepData
+func uninstall(dataDir string, scripts []string, keepData bool) (err error) {
+	dbDir := filepath.Join(dataDir, "server", "db")
+	backupDir := dataDir + "-db-backup"
+
+	if keepData {
+		if _, err := os.Stat(dbDir); err == nil {
+			logrus.Infof("Preserving %s in %s", dbDir, backupDir)
+			if err := os.Rename(dbDir, backupDir); err != nil {
+				return fmt.Errorf("preserving %s: %w", dbDir, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		defer func() {
+			if restoreErr := restoreData(dbDir, backupDir); restoreErr != nil {
+				logrus.Errorf("Failed to restore %s, it was left in %s", dbDir, backupDir)
+				err = errors.Join(err, restoreErr)
+			}
+		}()
+	}
+
+	for _, script := range scripts {
+		if _, err := os.Stat(script); os.IsNotExist(err) {
+			continue
+		}
+
+		logrus.Infof("Running %s", script)
+		cmd := exec.Command(script)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("running %s: %w", script, err)
+		}
+		break
+	}
+	return nil
+}
+
+// restoreData moves the datastore preserved in backupDir back to dbDir
+func restoreData(dbDir, backupDir string) error {
+	if _, err := os.Stat(backupDir); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dbDir), 0700); err != nil {
+		return err
+	}
+	if err := os.Rename(backupDir, dbDir); err != nil {
+		return fmt.Errorf("restoring %s from %s: %w", dbDir, backupDir, err)
+	}
+	logrus.Infof("Restored data to %s", dbDir)
+	return nil
+}
+
+// removePlanFiles removes every file written by the last applied plan
+func (o *OKR) removePlanFiles() error {
+	plan, err := plan2.Load(o.cfg.DataDir)
+	if err != nil {
+		return err
+	} else if plan == nil {
+		logrus.Infof("No plan found at %s, skipping plan files cleanup", plan2.GetPlanFile(o.cfg.DataDir))
+		return nil
+	}
+
+	for _, file := range plan.Files {
+		logrus.Infof("Removing %s", file.Path)
+		if err := os.RemoveAll(file.Path); err != nil {
+			return fmt.Errorf("removing %s: %w", file.Path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package okr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// writeScript writes an uninstall script that records its run in marker, removes the data dir
+// like the runtime's uninstall script and exits with exitCode
+func writeScript(t *testing.T, dataDir, marker string, exitCode int) string {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "uninstall.sh")
+	content := fmt.Sprintf("#!/bin/sh\ntouch %s\nrm -rf %s\nexit %d\n", marker, dataDir, exitCode)
+	if err := os.WriteFile(script, []byte(content), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestUninstall(t *testing.T) {
+	tests := []struct {
+		name     string
+		keepData bool
+		exitCode int
+		noScript bool
+		wantErr  bool
+		wantData bool
+	}{
+		{name: "remove data"},
+		{name: "keep data", keepData: true, wantData: true},
+		{name: "keep data when the uninstall fails", keepData: true, exitCode: 1, wantErr: true, wantData: true},
+		{name: "uninstall fails", exitCode: 1, wantErr: true},
+		{name: "no uninstall script", noScript: true, wantData: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataDir := filepath.Join(t.TempDir(), "k3s")
+			dbFile := filepath.Join(dataDir, "server", "db", "state.db")
+			if err := os.MkdirAll(filepath.Dir(dbFile), 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(dbFile, []byte("state"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			marker := filepath.Join(t.TempDir(), "ran")
+			scripts := []string{filepath.Join(t.TempDir(), "missing.sh")}
+			if !tt.noScript {
+				// only the first existing script runs
+				other := filepath.Join(t.TempDir(), "other")
+				scripts = append(scripts, writeScript(t, dataDir, marker, tt.exitCode), writeScript(t, dataDir, other, 0))
+				defer func() {
+					if _, err := os.Stat(other); err == nil {
+						t.Error("expected only the first uninstall script to run")
+					}
+				}()
+			}
+
+			err := uninstall(dataDir, scripts, tt.keepData)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if _, err := os.Stat(marker); (err == nil) == tt.noScript {
+				t.Errorf("expected the uninstall script to run: %v", !tt.noScript)
+			}
+			data, err := os.ReadFile(dbFile)
+			if tt.wantData {
+				if err != nil || string(data) != "state" {
+					t.Errorf("expected the datastore to be kept, got %q, %v", data, err)
+				}
+			} else if !os.IsNotExist(err) {
+				t.Errorf("expected the datastore to be removed, got %v", err)
+			}
+			if _, err := os.Stat(dataDir + "-db-backup"); !os.IsNotExist(err) {
+				t.Errorf("expected no backup to be left, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRestoreData(t *testing.T) {
+	dir := t.TempDir()
+	dbDir := filepath.Join(dir, "k3s", "server", "db")
+	backupDir := filepath.Join(dir, "k3s-db-backup")
+
+	// nothing was preserved
+	if err := restoreData(dbDir, backupDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dbDir); !os.IsNotExist(err) {
+		t.Errorf("expected no datastore, got %v", err)
+	}
+
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreData(dbDir, backupDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dbDir); err != nil {
+		t.Errorf("expected the datastore to be restored, got %v", err)
+	}
+}
+
+func newPod(name string, mutate func(*corev1.Pod)) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       corev1.PodSpec{NodeName: "node1"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	if mutate != nil {
+		mutate(pod)
+	}
+	return pod
+}
+
+func TestIsEvictable(t *testing.T) {
+	tests := []struct {
+		pod       *corev1.Pod
+		evictable bool
+	}{
+		{pod: newPod("running", nil), evictable: true},
+		{pod: newPod("pending", func(p *corev1.Pod) { p.Status.Phase = corev1.PodPending }), evictable: true},
+		{pod: newPod("replicaset", func(p *corev1.Pod) {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "rs"}}
+		}), evictable: true},
+		{pod: newPod("mirror", func(p *corev1.Pod) {
+			p.Annotations = map[string]string{mirrorPodAnnotation: "hash"}
+		})},
+		{pod: newPod("daemonset", func(p *corev1.Pod) {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "ds"}}
+		})},
+		{pod: newPod("succeeded", func(p *corev1.Pod) { p.Status.Phase = corev1.PodSucceeded })},
+		{pod: newPod("failed", func(p *corev1.Pod) { p.Status.Phase = corev1.PodFailed })},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pod.Name, func(t *testing.T) {
+			if got := isEvictable(tt.pod); got != tt.evictable {
+				t.Errorf("expected evictable %v, got %v", tt.evictable, got)
+			}
+		})
+	}
+}
+
+// evictions records the evicted pods, the first blocked evictions of a pod are answered
+// with a 429 like a pod disruption budget does
+func evictions(client *fake.Clientset, blocked map[string]int) *[]string {
+	var evicted []string
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		name := action.(k8stesting.CreateAction).GetObject().(metav1.Object).GetName()
+		if blocked[name] > 0 {
+			blocked[name]--
+			return true, nil, apierrors.NewTooManyRequests("disruption budget", 1)
+		}
+		evicted = append(evicted, name)
+		return true, nil, nil
+	})
+	return &evicted
+}
+
+func TestDrainNode(t *testing.T) {
+	interval := evictionRetryInterval
+	evictionRetryInterval = time.Millisecond
+	defer func() { evictionRetryInterval = interval }()
+
+	client := fake.NewSimpleClientset(
+		newPod("web", nil),
+		newPod("budget", nil),
+		newPod("daemonset", func(p *corev1.Pod) {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "ds"}}
+		}),
+		newPod("mirror", func(p *corev1.Pod) {
+			p.Annotations = map[string]string{mirrorPodAnnotation: "hash"}
+		}),
+		newPod("completed", func(p *corev1.Pod) { p.Status.Phase = corev1.PodSucceeded }),
+	)
+	evicted := evictions(client, map[string]int{"budget": 2})
+
+	if err := drainNode(context.Background(), client, "node1"); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(*evicted)
+	if expected := []string{"budget", "web"}; !reflect.DeepEqual(*evicted, expected) {
+		t.Errorf("expected evicted pods %v, got %v", expected, *evicted)
+	}
+}
+
+func TestDrainNodeBlocked(t *testing.T) {
+	interval := evictionRetryInterval
+	evictionRetryInterval = time.Millisecond
+	defer func() { evictionRetryInterval = interval }()
+
+	client := fake.NewSimpleClientset(newPod("budget", nil))
+	evictions(client, map[string]int{"budget": 1 << 30})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := drainNode(ctx, client, "node1"); err != context.DeadlineExceeded {
+		t.Errorf("expected the drain to stop at the timeout, got %v", err)
+	}
+}
+
+func TestDrainNodeEvictionError(t *testing.T) {
+	client := fake.NewSimpleClientset(newPod("web", nil))
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "web", fmt.Errorf("not allowed"))
+	})
+
+	if err := drainNode(context.Background(), client, "node1"); !apierrors.IsForbidden(err) {
+		t.Errorf("expected the eviction error, got %v", err)
+	}
+}
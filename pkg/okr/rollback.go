@@ -0,0 +1,52 @@
+package okr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	plan2 "github.com/oneblock-ai/okr/pkg/k3s/plan"
+	"github.com/oneblock-ai/okr/pkg/k3s/versions"
+)
+
+// Rollback re-applies the plan of an earlier revision of the plan history
+func (o *OKR) Rollback(ctx context.Context, number int) error {
+	revision, err := plan2.GetRevision(o.cfg.DataDir, number)
+	if err != nil {
+		return err
+	}
+	nodePlan, err := plan2.LoadRevision(revision)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := o.bootstrappedConfig()
+	if err != nil {
+		return err
+	}
+	if cfg == nil {
+		loaded, err := config.Load(o.cfg.ConfigPath)
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		cfg = &loaded
+	}
+	if err := o.configureVersions(cfg); err != nil {
+		return err
+	}
+	// the Kubernetes version only selects the runtime, the version installed is the one of the revision
+	k8sVersion, err := versions.K8sVersion(cfg.KubernetesVersion)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Rolling back to plan revision %d (%s)", revision.Number, revision.Checksum)
+	if err := plan2.RunWithKubernetesVersion(ctx, k8sVersion, nodePlan, o.cfg.DataDir, cfg.RetryPolicy.InstructionAttempts); err != nil {
+		return fmt.Errorf("running plan: %w", err)
+	}
+
+	logrus.Infof("Successfully rolled back to plan revision %d, the bootstrapped config in %s is not changed", revision.Number, o.DoneStamp())
+	return nil
+}
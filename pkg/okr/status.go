@@ -0,0 +1,297 @@
+package okr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/probe"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/resources"
+	plan2 "github.com/oneblock-ai/okr/pkg/k3s/plan"
+	"github.com/oneblock-ai/okr/pkg/k3s/versions"
+	"github.com/oneblock-ai/okr/pkg/version"
+)
+
+const nodeRoleLabelPrefix = "node-role.kubernetes.io/"
+
+var helmChartResource = schema.GroupVersionResource{
+	Group:    "helm.cattle.io",
+	Version:  "v1",
+	Resource: "helmcharts",
+}
+
+// Status is a report of the node and cluster, every section carries the error that
+// prevented it from being collected instead of failing the whole report
+type Status struct {
+	OKRVersion string        `json:"okrVersion"`
+	Node       NodeStatus    `json:"node"`
+	Plan       PlanStatus    `json:"plan"`
+	Probes     ProbesStatus  `json:"probes"`
+	Kubernetes ClusterStatus `json:"kubernetes"`
+	KubeRay    KubeRayStatus `json:"kuberay"`
+}
+
+type NodeStatus struct {
+	Name              string     `json:"name,omitempty"`
+	Role              string     `json:"role,omitempty"`
+	Runtime           string     `json:"runtime,omitempty"`
+	KubernetesVersion string     `json:"kubernetesVersion,omitempty"`
+	Bootstrapped      bool       `json:"bootstrapped"`
+	BootstrappedAt    *time.Time `json:"bootstrappedAt,omitempty"`
+	Error             string     `json:"error,omitempty"`
+}
+
+type PlanStatus struct {
+	File     string `json:"file,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ProbesStatus struct {
+	Results []probe.Result `json:"results,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+type ClusterStatus struct {
+	Version string        `json:"version,omitempty"`
+	Nodes   []ClusterNode `json:"nodes,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+type ClusterNode struct {
+	Name    string   `json:"name"`
+	Roles   []string `json:"roles,omitempty"`
+	Ready   bool     `json:"ready"`
+	Version string   `json:"version,omitempty"`
+}
+
+type KubeRayStatus struct {
+	Version    string            `json:"version,omitempty"`
+	Image      string            `json:"image,omitempty"`
+	HelmCharts []HelmChartStatus `json:"helmCharts,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+type HelmChartStatus struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Job     string `json:"job,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Status collects the status of the node and the cluster it belongs to
+func (o *OKR) Status(ctx context.Context) *Status {
+	status := &Status{
+		OKRVersion: version.FriendlyVersion(),
+	}
+
+	cfg, err := o.nodeStatus(&status.Node)
+	if err != nil {
+		status.Node.Error = err.Error()
+	}
+
+	status.Plan.File = plan2.GetPlanFile(o.cfg.DataDir)
+	if status.Plan.Checksum, err = checksum(status.Plan.File); err != nil {
+		status.Plan.Error = err.Error()
+	}
+
+	if status.Probes.Results, err = checkProbes(ctx, status.Plan.File, probe.GetProbesFile(o.cfg.DataDir)); err != nil {
+		status.Probes.Error = err.Error()
+	}
+
+	k8s, err := newK8sClient()
+	if err != nil {
+		status.Kubernetes.Error = err.Error()
+		status.KubeRay.Error = err.Error()
+		return status
+	}
+
+	if err := clusterStatus(ctx, k8s, &status.Kubernetes); err != nil {
+		status.Kubernetes.Error = err.Error()
+	}
+
+	var kubeRayErrs []string
+	if status.KubeRay.Version, status.KubeRay.Image, err = getKubeRayVersion(ctx, k8s); err != nil {
+		kubeRayErrs = append(kubeRayErrs, err.Error())
+	}
+	kubeRay := &config.KubeRay{}
+	if cfg != nil {
+		kubeRay = &cfg.KubeRay
+	}
+	if kubeRay.IsEnabled() {
+		if status.KubeRay.HelmCharts, err = helmChartStatuses(ctx, k8s, kubeRay); err != nil {
+			kubeRayErrs = append(kubeRayErrs, err.Error())
+		}
+	}
+	status.KubeRay.Error = strings.Join(kubeRayErrs, "; ")
+
+	return status
+}
+
+// nodeStatus fills in the node details from the bootstrapped config, falling back to the
+// config file for nodes that are not bootstrapped yet
+func (o *OKR) nodeStatus(node *NodeStatus) (*config.Config, error) {
+	cfg, err := o.bootstrappedConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		node.Bootstrapped = true
+		if info, err := os.Stat(o.DoneStamp()); err == nil {
+			modTime := info.ModTime()
+			node.BootstrappedAt = &modTime
+		}
+	} else {
+		loaded, err := config.Load(o.cfg.ConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading config: %w", err)
+		}
+		cfg = &loaded
+	}
+
+	node.Role = cfg.Role
+	if err := o.configureVersions(cfg); err != nil {
+		return cfg, err
+	}
+	if node.Name, err = config.GetNodeName(&cfg.RuntimeConfig); err != nil {
+		return cfg, err
+	}
+
+	k8sVersion, err := versions.K8sVersion(cfg.KubernetesVersion)
+	if err != nil {
+		return cfg, fmt.Errorf("resolving kubernetes version: %w", err)
+	}
+	node.KubernetesVersion = k8sVersion
+	node.Runtime = string(config.GetRuntime(k8sVersion))
+	return cfg, nil
+}
+
+func checksum(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func checkProbes(ctx context.Context, planFile, probesFile string) ([]probe.Result, error) {
+	probes, err := probe.LoadProbes(planFile, probesFile)
+	if err != nil {
+		return nil, err
+	}
+	return probe.CheckOnce(ctx, probes), nil
+}
+
+func clusterStatus(ctx context.Context, k8s kubernetes.Interface, cluster *ClusterStatus) error {
+	serverVersion, err := k8s.Discovery().ServerVersion()
+	if err != nil {
+		return fmt.Errorf("getting server version: %w", err)
+	}
+	cluster.Version = serverVersion.GitVersion
+
+	nodes, err := k8s.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing nodes: %w", err)
+	}
+	for _, node := range nodes.Items {
+		cluster.Nodes = append(cluster.Nodes, ClusterNode{
+			Name:    node.Name,
+			Roles:   nodeRoles(&node),
+			Ready:   isNodeReady(&node),
+			Version: node.Status.NodeInfo.KubeletVersion,
+		})
+	}
+	return nil
+}
+
+func nodeRoles(node *corev1.Node) []string {
+	var roles []string
+	for label := range node.Labels {
+		if role, ok := strings.CutPrefix(label, nodeRoleLabelPrefix); ok && role != "" {
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+func isNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func helmChartStatuses(ctx context.Context, k8s kubernetes.Interface, kubeRay *config.KubeRay) ([]HelmChartStatus, error) {
+	dynamicClient, err := newDynamicClient()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{resources.KubeRayOperatorChart}
+	if kubeRay.APIServer != nil {
+		names = append(names, resources.KubeRayAPIServerChart)
+	}
+
+	var result []HelmChartStatus
+	for _, name := range names {
+		chartStatus := HelmChartStatus{Name: name}
+		chart, err := dynamicClient.Resource(helmChartResource).Namespace(resources.HelmChartNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			chartStatus.Error = err.Error()
+			result = append(result, chartStatus)
+			continue
+		}
+
+		chartStatus.Version, _, _ = unstructured.NestedString(chart.Object, "spec", "version")
+		chartStatus.Job, _, _ = unstructured.NestedString(chart.Object, "status", "jobName")
+		if chartStatus.Job == "" {
+			chartStatus.Status = "Pending"
+		} else if chartStatus.Status, err = jobStatus(ctx, k8s, chartStatus.Job); err != nil {
+			chartStatus.Error = err.Error()
+		}
+		result = append(result, chartStatus)
+	}
+	return result, nil
+}
+
+func jobStatus(ctx context.Context, k8s kubernetes.Interface, name string) (string, error) {
+	job, err := k8s.BatchV1().Jobs(resources.HelmChartNamespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		// the helm controller removes finished jobs after a while
+		return "Unknown", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return "Installed", nil
+		case batchv1.JobFailed:
+			return "Failed", nil
+		}
+	}
+	return "Installing", nil
+}
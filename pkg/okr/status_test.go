@@ -0,0 +1,101 @@
+package okr
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	versionutil "k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/resources"
+)
+
+func TestNodeStatus(t *testing.T) {
+	o := newAgentOKR(t, agentConfig)
+
+	node := NodeStatus{}
+	if _, err := o.nodeStatus(&node); err != nil {
+		t.Fatal(err)
+	}
+	want := NodeStatus{Name: "node1", Role: "cluster-init", Runtime: "k3s", KubernetesVersion: "v1.28.4+k3s2"}
+	if !reflect.DeepEqual(node, want) {
+		t.Errorf("expected %+v, got %+v", want, node)
+	}
+
+	// the bootstrapped config is reported over the config file
+	bootstrap(t, o, "role: agent\nkubernetesVersion: v1.28.4+rke2r1\nnodeName: node1\n")
+	node = NodeStatus{}
+	if _, err := o.nodeStatus(&node); err != nil {
+		t.Fatal(err)
+	}
+	if !node.Bootstrapped || node.BootstrappedAt == nil || node.Role != "agent" || node.Runtime != "rke2" {
+		t.Errorf("expected the bootstrapped rke2 agent, got %+v", node)
+	}
+}
+
+func TestClusterStatus(t *testing.T) {
+	k8s := fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{
+				"node-role.kubernetes.io/control-plane": "true",
+				"node-role.kubernetes.io/etcd":          "true",
+				"kubernetes.io/hostname":                "node1",
+			}},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+				NodeInfo:   corev1.NodeSystemInfo{KubeletVersion: "v1.28.4+k3s2"},
+			},
+		},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2"}},
+	)
+	k8s.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &versionutil.Info{GitVersion: "v1.28.4+k3s2"}
+
+	cluster := ClusterStatus{}
+	if err := clusterStatus(context.Background(), k8s, &cluster); err != nil {
+		t.Fatal(err)
+	}
+	want := ClusterStatus{
+		Version: "v1.28.4+k3s2",
+		Nodes: []ClusterNode{
+			{Name: "node1", Roles: []string{"control-plane", "etcd"}, Ready: true, Version: "v1.28.4+k3s2"},
+			{Name: "node2"},
+		},
+	}
+	if !reflect.DeepEqual(cluster, want) {
+		t.Errorf("expected %+v, got %+v", want, cluster)
+	}
+}
+
+func TestJobStatus(t *testing.T) {
+	job := func(name string, conditions ...batchv1.JobCondition) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Namespace: resources.HelmChartNamespace, Name: name},
+			Status:     batchv1.JobStatus{Conditions: conditions},
+		}
+	}
+	k8s := fake.NewSimpleClientset(
+		job("complete", batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}),
+		job("failed", batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}),
+		job("running", batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionFalse}),
+	)
+
+	for name, want := range map[string]string{
+		"complete": "Installed",
+		"failed":   "Failed",
+		"running":  "Installing",
+		"removed":  "Unknown",
+	} {
+		got, err := jobStatus(context.Background(), k8s, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+}
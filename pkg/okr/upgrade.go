@@ -0,0 +1,123 @@
+package okr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+
+	"github.com/oneblock-ai/okr/pkg/k3s/config"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/probe"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/resources"
+	plan2 "github.com/oneblock-ai/okr/pkg/k3s/plan"
+	"github.com/oneblock-ai/okr/pkg/k3s/versions"
+)
+
+const probeInterval = 5 * time.Second
+
+func (o *OKR) Upgrade(ctx context.Context, upgrade UpgradeConfig) error {
+	if upgrade.KubernetesVersion == "" && upgrade.KubeRayVersion == "" {
+		return fmt.Errorf("at least one of kubernetes version or kuberay version is required")
+	}
+
+	current, err := o.bootstrappedConfig()
+	if err != nil {
+		return err
+	}
+	if current == nil && !upgrade.Force {
+		return fmt.Errorf("system is not bootstrapped, run bootstrap first or upgrade with the --force flag")
+	}
+
+	cfg, err := config.Load(o.cfg.ConfigPath)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if err := o.configureVersions(&cfg); err != nil {
+		return err
+	}
+	if current != nil {
+		cfg.KubernetesVersion = current.KubernetesVersion
+		cfg.KubeRay.Version = current.KubeRay.Version
+	}
+
+	if upgrade.KubernetesVersion != "" {
+		k8sVersion, err := versions.K8sVersion(upgrade.KubernetesVersion)
+		if err != nil {
+			return err
+		}
+		cfg.KubernetesVersion = k8sVersion
+	}
+	if upgrade.KubeRayVersion != "" {
+		cfg.KubeRay.Version = upgrade.KubeRayVersion
+	}
+
+	if current != nil && !upgrade.Force &&
+		current.KubernetesVersion == cfg.KubernetesVersion &&
+		current.KubeRay.Version == cfg.KubeRay.Version {
+		logrus.Infof("System is already at Kubernetes (%s) and KubeRay (%s). To force the upgrade run with the --force flag",
+			cfg.KubernetesVersion, resources.KubeRayVersion(&cfg.KubeRay))
+		return nil
+	}
+
+	if err := o.setWorking(cfg); err != nil {
+		return fmt.Errorf("saving working config to %s: %w", o.WorkingStamp(), err)
+	}
+
+	logrus.Infof("Upgrading Kubernetes (%s) and KubeRay (%s)", cfg.KubernetesVersion, resources.KubeRayVersion(&cfg.KubeRay))
+
+	if err := o.apply(ctx, cfg); err != nil {
+		return err
+	}
+
+	logrus.Infof("Successfully upgraded Kubernetes (%s) and KubeRay (%s)", cfg.KubernetesVersion, resources.KubeRayVersion(&cfg.KubeRay))
+	return nil
+}
+
+// apply runs the plan of an already bootstrapped node, waits for its probes and records
+// cfg in the done stamp
+func (o *OKR) apply(ctx context.Context, cfg config.Config) error {
+	nodePlan, err := plan2.ToPlan(ctx, withoutBootstrapInstructions(cfg), o.cfg.DataDir)
+	if err != nil {
+		return fmt.Errorf("generating plan: %w", err)
+	}
+
+	if err := plan2.Run(ctx, &cfg, nodePlan, o.cfg.DataDir); err != nil {
+		return fmt.Errorf("running plan: %w", err)
+	}
+
+	if _, err := probe.RunProbes(ctx, plan2.GetPlanFile(o.cfg.DataDir), probe.GetProbesFile(o.cfg.DataDir), probe.RunConfig{
+		Interval: probeInterval,
+	}); err != nil {
+		return fmt.Errorf("waiting for probes: %w", err)
+	}
+
+	return o.setDone(cfg)
+}
+
+// withoutBootstrapInstructions drops the pre and post instructions that are only meant to be
+// run once on bootstrap
+func withoutBootstrapInstructions(cfg config.Config) *config.Config {
+	cfg.PreOneTimeInstructions = nil
+	cfg.PostOneTimeInstructions = nil
+	return &cfg
+}
+
+// bootstrappedConfig returns the config recorded in the done stamp, or nil if the system is not bootstrapped
+func (o *OKR) bootstrappedConfig() (*config.Config, error) {
+	data, err := os.ReadFile(o.DoneStamp())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading done stamp [%s]: %w", o.DoneStamp(), err)
+	}
+
+	cfg := &config.Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing done stamp [%s]: %w", o.DoneStamp(), err)
+	}
+	cfg.MigrateDeprecated()
+	return cfg, nil
+}
@@ -2,61 +2,47 @@ package okr
 
 import (
 	"context"
-	"os"
+	"fmt"
+	"strings"
 
-	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 
-	"github.com/oneblock-ai/okr/pkg/k3s/kubectl"
-	"github.com/oneblock-ai/okr/pkg/version"
+	"github.com/oneblock-ai/okr/pkg/k3s/instructions/resources"
 )
 
-func (o *OKR) Info(ctx context.Context) error {
-	k8sVersion, kubeRayVersion := o.getExistingVersions(ctx)
-
-	logrus.Infof("Kubernetes version: %s\n", k8sVersion)
-	logrus.Infof("KubeRay version: %s\n", kubeRayVersion)
-	logrus.Infof("OKR version: %s\n\n", version.FriendlyVersion())
-	return nil
-}
+const (
+	kubeRayOperatorSelector = "app.kubernetes.io/name=" + resources.KubeRayOperatorChart
+	helmChartLabel          = "helm.sh/chart"
+	appVersionLabel         = "app.kubernetes.io/version"
+)
 
-func (o *OKR) getExistingVersions(ctx context.Context) (k8sVersion, kubeRayVersion string) {
-	kubeConfig, err := kubectl.GetKubeconfig("")
+// getKubeRayVersion returns the KubeRay operator version and image from its deployment
+func getKubeRayVersion(ctx context.Context, k8s kubernetes.Interface) (version, image string, err error) {
+	deployments, err := k8s.AppsV1().Deployments("").List(ctx, metav1.ListOptions{
+		LabelSelector: kubeRayOperatorSelector,
+	})
 	if err != nil {
-		return "", ""
+		return "", "", err
 	}
-
-	data, err := os.ReadFile(kubeConfig)
-	if err != nil {
-		return "", ""
+	if len(deployments.Items) == 0 {
+		return "", "", fmt.Errorf("no deployment found with label %s", kubeRayOperatorSelector)
 	}
 
-	restConfig, err := clientcmd.RESTConfigFromKubeConfig(data)
-	if err != nil {
-		return "", ""
+	deployment := deployments.Items[0]
+	if containers := deployment.Spec.Template.Spec.Containers; len(containers) > 0 {
+		image = containers[0].Image
 	}
 
-	k8s, err := kubernetes.NewForConfig(restConfig)
-	if err != nil {
-		return "", ""
+	// the chart label is the most reliable as the image tag can be overridden in the values
+	if chart, ok := deployment.Labels[helmChartLabel]; ok && strings.HasPrefix(chart, resources.KubeRayOperatorChart+"-") {
+		return strings.TrimPrefix(chart, resources.KubeRayOperatorChart+"-"), image, nil
 	}
-
-	return getK8sVersion(ctx, k8s), getKubeRayVersion()
-}
-
-func getK8sVersion(ctx context.Context, k8s kubernetes.Interface) string {
-	nodes, err := k8s.CoreV1().Nodes().List(ctx, metav1.ListOptions{
-		LabelSelector: "node-role.kubernetes.io/control-plane=true",
-	})
-	if err != nil || len(nodes.Items) == 0 {
-		return ""
+	if appVersion, ok := deployment.Labels[appVersionLabel]; ok {
+		return appVersion, image, nil
 	}
-	return nodes.Items[0].Status.NodeInfo.KubeletVersion
-}
-
-func getKubeRayVersion() string {
-	//TODO: add get KubeRay version by its deployment
-	return ""
+	if i := strings.LastIndex(image, ":"); i > 0 && !strings.Contains(image[i:], "/") {
+		return strings.TrimPrefix(image[i+1:], "v"), image, nil
+	}
+	return "", image, fmt.Errorf("unable to determine version of deployment %s/%s", deployment.Namespace, deployment.Name)
 }
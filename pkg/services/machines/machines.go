@@ -0,0 +1,320 @@
+// Package machines manages the machines of an Ok3sControlPlane.
+package machines
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/external"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/collections"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/failuredomains"
+	"sigs.k8s.io/cluster-api/util/labels/format"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	bootstrapv1 "github.com/oneblock-ai/okr/api/bootstrap/v1"
+	controlplanev1 "github.com/oneblock-ai/okr/api/controlplane/v1"
+	"github.com/oneblock-ai/okr/pkg/scope"
+)
+
+// requeueAfter is how long to wait for machines to be created, bootstrapped or deleted
+const requeueAfter = 20 * time.Second
+
+// Service creates the control plane machines up to the desired replicas and deletes the
+// surplus, one machine at a time.
+type Service struct {
+	scope *scope.ControlPlaneScope
+}
+
+// NewService returns a machine Service of the control plane in scope.
+func NewService(scope *scope.ControlPlaneScope) *Service {
+	return &Service{
+		scope: scope,
+	}
+}
+
+// Reconcile updates the status of the control plane and scales its machines towards spec.replicas.
+// Machines are only added or removed while all existing machines are ready, which keeps etcd quorum.
+func (s *Service) Reconcile(ctx context.Context) (ctrl.Result, error) {
+	cp := s.scope.ControlPlane
+	cluster := s.scope.Cluster
+	logger := s.scope.Logger
+
+	if !cluster.Status.InfrastructureReady {
+		logger.Info("Waiting for the cluster infrastructure to be ready")
+		return ctrl.Result{}, nil
+	}
+
+	machines, err := s.controlPlaneMachines(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	s.updateStatus(machines)
+
+	if len(machines.Filter(collections.HasDeletionTimestamp)) > 0 {
+		logger.Info("Waiting for control plane machines to be deleted")
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	desired := int(desiredReplicas(cp))
+	switch {
+	case machines.Len() == 0:
+		// the first machine initializes the cluster
+		return s.scaleUp(ctx, machines)
+	case !cp.Status.Initialized:
+		logger.Info("Waiting for the first control plane machine to initialize the cluster")
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	case machines.Len() != desired && int(cp.Status.ReadyReplicas) < machines.Len():
+		logger.Info("Waiting for all control plane machines to be ready before scaling",
+			"replicas", machines.Len(), "readyReplicas", cp.Status.ReadyReplicas)
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	case machines.Len() < desired:
+		return s.scaleUp(ctx, machines)
+	case machines.Len() > desired:
+		return s.scaleDown(ctx, machines)
+	}
+
+	conditions.MarkTrue(cp, controlplanev1.ResizedCondition)
+	return ctrl.Result{}, nil
+}
+
+// Delete deletes all control plane machines and waits for them to be gone.
+func (s *Service) Delete(ctx context.Context) (ctrl.Result, error) {
+	machines, err := s.controlPlaneMachines(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if machines.Len() == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	var errs []error
+	for _, m := range machines.Filter(collections.Not(collections.HasDeletionTimestamp)) {
+		if err := s.scope.Client.Delete(ctx, m); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete control plane machine %s: %w", m.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return ctrl.Result{}, kerrors.NewAggregate(errs)
+	}
+
+	s.scope.Logger.Info("Waiting for control plane machines to be deleted", "machines", machines.Len())
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+func (s *Service) controlPlaneMachines(ctx context.Context) (collections.Machines, error) {
+	machines, err := collections.GetFilteredMachinesForCluster(ctx, s.scope.Client, s.scope.Cluster,
+		collections.ControlPlaneMachines(s.scope.Cluster.Name),
+		s.ownedMachines)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list control plane machines: %w", err)
+	}
+	return machines, nil
+}
+
+// ownedMachines matches the machines controlled by the control plane, the controller reference
+// is compared by UID as the TypeMeta of the control plane may be empty
+func (s *Service) ownedMachines(machine *clusterv1.Machine) bool {
+	ref := metav1.GetControllerOf(machine)
+	return ref != nil && ref.UID == s.scope.ControlPlane.UID
+}
+
+func (s *Service) updateStatus(machines collections.Machines) {
+	cp := s.scope.ControlPlane
+	status := &cp.Status
+
+	status.Selector = collections.ControlPlaneSelectorForCluster(s.scope.Cluster.Name).String()
+	status.Replicas = int32(machines.Len())
+	status.UpdatedReplicas = int32(machines.Filter(collections.MatchesKubernetesVersion(cp.Spec.Version)).Len())
+	status.ReadyReplicas = int32(machines.Filter(isReady).Len())
+	status.Version = machines.LowestVersion()
+	if !status.Initialized {
+		status.Initialized = machines.Filter(hasNode).Len() > 0
+	}
+	status.Ready = status.ReadyReplicas > 0
+	status.ObservedGeneration = cp.Generation
+
+	if status.Initialized {
+		conditions.MarkTrue(cp, controlplanev1.AvailableCondition)
+	} else {
+		conditions.MarkFalse(cp, controlplanev1.AvailableCondition, controlplanev1.WaitingForBootstrapReason,
+			clusterv1.ConditionSeverityInfo, "Waiting for the first control plane machine to bootstrap the cluster")
+	}
+	conditions.SetAggregate(cp, controlplanev1.MachinesReadyCondition, machines.ConditionGetters(),
+		conditions.AddSourceRef(), conditions.WithStepCounterIf(false))
+}
+
+func (s *Service) scaleUp(ctx context.Context, machines collections.Machines) (ctrl.Result, error) {
+	cp := s.scope.ControlPlane
+	conditions.MarkFalse(cp, controlplanev1.ResizedCondition, controlplanev1.ScalingUpReason, clusterv1.ConditionSeverityWarning,
+		"Scaling up control plane to %d replicas (actual %d)", desiredReplicas(cp), machines.Len())
+
+	failureDomain := failuredomains.PickFewest(s.scope.Cluster.Status.FailureDomains.FilterControlPlane(), machines)
+	if err := s.createMachine(ctx, failureDomain); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// scaleDown deletes the oldest machine of the failure domain with the most machines, which may
+// be the machine that initialized the cluster. The etcd member of the machine is not removed
+// here, k3s and RKE2 remove it when the Machine controller deletes the node of the machine.
+// The machine is only deleted while the node of every other machine is ready, so that the
+// remaining etcd members keep quorum.
+func (s *Service) scaleDown(ctx context.Context, machines collections.Machines) (ctrl.Result, error) {
+	cp := s.scope.ControlPlane
+	conditions.MarkFalse(cp, controlplanev1.ResizedCondition, controlplanev1.ScalingDownReason, clusterv1.ConditionSeverityWarning,
+		"Scaling down control plane to %d replicas (actual %d)", desiredReplicas(cp), machines.Len())
+
+	candidates := machines
+	failureDomains := s.scope.Cluster.Status.FailureDomains.FilterControlPlane()
+	if failureDomain := failuredomains.PickMost(failureDomains, machines, machines); failureDomain != nil {
+		candidates = machines.Filter(collections.InFailureDomains(failureDomain))
+	}
+	machine := candidates.Oldest()
+	if machine == nil {
+		return ctrl.Result{}, fmt.Errorf("failed to pick a control plane machine to delete")
+	}
+
+	if unready := machines.Difference(collections.FromMachines(machine)).Filter(collections.Not(isReady)); unready.Len() > 0 {
+		s.scope.Logger.Info("Waiting for the nodes of the remaining control plane machines to be ready before scaling down",
+			"machine", machine.Name, "unready", unready.Names())
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	s.scope.Logger.Info("Deleting control plane machine", "machine", machine.Name)
+	if err := s.scope.Client.Delete(ctx, machine); err != nil && !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, fmt.Errorf("failed to delete control plane machine %s: %w", machine.Name, err)
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// createMachine clones the infrastructure template and creates the bootstrap config and the
+// machine using both, the cloned objects are deleted when the machine can't be created.
+func (s *Service) createMachine(ctx context.Context, failureDomain *string) error {
+	cp := s.scope.ControlPlane
+	cluster := s.scope.Cluster
+	ownerRef := metav1.NewControllerRef(cp, controlplanev1.GroupVersion.WithKind("Ok3sControlPlane"))
+	name := fmt.Sprintf("%s-%s", cp.Name, util.RandomString(5))
+
+	infraRef, err := external.CreateFromTemplate(ctx, &external.CreateFromTemplateInput{
+		Client:      s.scope.Client,
+		TemplateRef: &cp.Spec.MachineTemplate.InfrastructureRef,
+		Namespace:   cp.Namespace,
+		ClusterName: cluster.Name,
+		OwnerRef:    ownerRef,
+		Labels:      s.machineLabels(),
+		Annotations: cp.Spec.MachineTemplate.ObjectMeta.Annotations,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clone infrastructure template %s: %w", cp.Spec.MachineTemplate.InfrastructureRef.Name, err)
+	}
+
+	bootstrapRef, err := s.createBootstrapConfig(ctx, name, ownerRef)
+	if err != nil {
+		return kerrors.NewAggregate([]error{err, s.deleteExternal(ctx, infraRef)})
+	}
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       cp.Namespace,
+			Labels:          s.machineLabels(),
+			Annotations:     cp.Spec.MachineTemplate.ObjectMeta.Annotations,
+			OwnerReferences: []metav1.OwnerReference{*ownerRef},
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName:       cluster.Name,
+			Version:           pointer.String(cp.Spec.Version),
+			InfrastructureRef: *infraRef,
+			Bootstrap: clusterv1.Bootstrap{
+				ConfigRef: bootstrapRef,
+			},
+			FailureDomain: failureDomain,
+		},
+	}
+	s.scope.Logger.Info("Creating control plane machine", "machine", name, "failureDomain", pointer.StringDeref(failureDomain, ""))
+	if err := s.scope.Client.Create(ctx, machine); err != nil {
+		err = fmt.Errorf("failed to create control plane machine %s: %w", name, err)
+		return kerrors.NewAggregate([]error{err, s.deleteExternal(ctx, infraRef), s.deleteExternal(ctx, bootstrapRef)})
+	}
+	return nil
+}
+
+func (s *Service) createBootstrapConfig(ctx context.Context, name string, ownerRef *metav1.OwnerReference) (*corev1.ObjectReference, error) {
+	cp := s.scope.ControlPlane
+	spec := cp.Spec.Ok3sConfigSpec.DeepCopy()
+	spec.Version = cp.Spec.Version
+
+	config := &bootstrapv1.Ok3sConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       cp.Namespace,
+			Labels:          s.machineLabels(),
+			Annotations:     cp.Spec.MachineTemplate.ObjectMeta.Annotations,
+			OwnerReferences: []metav1.OwnerReference{*ownerRef},
+		},
+		Spec: *spec,
+	}
+	if err := s.scope.Client.Create(ctx, config); err != nil {
+		return nil, fmt.Errorf("failed to create bootstrap config %s: %w", name, err)
+	}
+
+	return &corev1.ObjectReference{
+		APIVersion: bootstrapv1.GroupVersion.String(),
+		Kind:       "Ok3sConfig",
+		Name:       config.Name,
+		Namespace:  config.Namespace,
+		UID:        config.UID,
+	}, nil
+}
+
+func (s *Service) deleteExternal(ctx context.Context, ref *corev1.ObjectReference) error {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(ref.APIVersion)
+	obj.SetKind(ref.Kind)
+	obj.SetNamespace(ref.Namespace)
+	obj.SetName(ref.Name)
+	if err := s.scope.Client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to clean up %s %s: %w", ref.Kind, ref.Name, err)
+	}
+	return nil
+}
+
+// machineLabels returns the labels of the template with the control plane labels, which
+// select the machines of the control plane.
+func (s *Service) machineLabels() map[string]string {
+	labels := map[string]string{}
+	for k, v := range s.scope.ControlPlane.Spec.MachineTemplate.ObjectMeta.Labels {
+		labels[k] = v
+	}
+	labels[clusterv1.ClusterNameLabel] = s.scope.Cluster.Name
+	labels[clusterv1.MachineControlPlaneLabel] = ""
+	labels[clusterv1.MachineControlPlaneNameLabel] = format.MustFormatValue(s.scope.ControlPlane.Name)
+	return labels
+}
+
+// desiredReplicas returns spec.replicas, the control plane keeps at least one machine
+func desiredReplicas(cp *controlplanev1.Ok3sControlPlane) int32 {
+	if cp.Spec.Replicas == nil || *cp.Spec.Replicas < 1 {
+		return 1
+	}
+	return *cp.Spec.Replicas
+}
+
+func hasNode(machine *clusterv1.Machine) bool {
+	return machine.Status.NodeRef != nil
+}
+
+func isReady(machine *clusterv1.Machine) bool {
+	return hasNode(machine) && conditions.IsTrue(machine, clusterv1.ReadyCondition)
+}
@@ -0,0 +1,420 @@
+package machines
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	bootstrapv1 "github.com/oneblock-ai/okr/api/bootstrap/v1"
+	controlplanev1 "github.com/oneblock-ai/okr/api/controlplane/v1"
+	"github.com/oneblock-ai/okr/pkg/scope"
+)
+
+const version = "v1.28.4+k3s2"
+
+var (
+	infraTemplateGVK = schema.GroupVersionKind{Group: "infrastructure.cluster.x-k8s.io", Version: "v1beta1", Kind: "TestMachineTemplate"}
+	infraMachineGVK  = schema.GroupVersionKind{Group: "infrastructure.cluster.x-k8s.io", Version: "v1beta1", Kind: "TestMachine"}
+	baseTime         = time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{
+		clientgoscheme.AddToScheme,
+		clusterv1.AddToScheme,
+		bootstrapv1.AddToScheme,
+		controlplanev1.AddToScheme,
+	} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the infrastructure objects have no Go types, they are handled as unstructured objects
+	for _, gvk := range []schema.GroupVersionKind{infraTemplateGVK, infraMachineGVK} {
+		scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+	}
+	return scheme
+}
+
+func newCluster(failureDomains ...string) *clusterv1.Cluster {
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"},
+		Status:     clusterv1.ClusterStatus{InfrastructureReady: true},
+	}
+	for _, fd := range failureDomains {
+		if cluster.Status.FailureDomains == nil {
+			cluster.Status.FailureDomains = clusterv1.FailureDomains{}
+		}
+		cluster.Status.FailureDomains[fd] = clusterv1.FailureDomainSpec{ControlPlane: true}
+	}
+	return cluster
+}
+
+func newControlPlane(replicas int32) *controlplanev1.Ok3sControlPlane {
+	return &controlplanev1.Ok3sControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test-cp", UID: "cp-uid", Generation: 2},
+		Spec: controlplanev1.Ok3sControlPlaneSpec{
+			Replicas: pointer.Int32(replicas),
+			Version:  version,
+			MachineTemplate: controlplanev1.Ok3sControlPlaneMachineTemplate{
+				ObjectMeta: clusterv1.ObjectMeta{Labels: map[string]string{"role": "server"}},
+				InfrastructureRef: corev1.ObjectReference{
+					APIVersion: infraTemplateGVK.GroupVersion().String(),
+					Kind:       infraTemplateGVK.Kind,
+					Namespace:  "default",
+					Name:       "test-template",
+				},
+			},
+		},
+	}
+}
+
+func newInfraTemplate() *unstructured.Unstructured {
+	template := &unstructured.Unstructured{}
+	template.SetGroupVersionKind(infraTemplateGVK)
+	template.SetNamespace("default")
+	template.SetName("test-template")
+	if err := unstructured.SetNestedField(template.Object, map[string]interface{}{
+		"spec": map[string]interface{}{"image": "okr"},
+	}, "spec", "template"); err != nil {
+		panic(err)
+	}
+	return template
+}
+
+// newMachine returns a control plane machine of cp created age minutes after baseTime, a ready
+// machine has a node and a true Ready condition.
+func newMachine(cp *controlplanev1.Ok3sControlPlane, name, failureDomain string, age int, ready bool) *clusterv1.Machine {
+	m := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(baseTime.Add(time.Duration(age) * time.Minute)),
+			Labels: map[string]string{
+				clusterv1.ClusterNameLabel:         "test",
+				clusterv1.MachineControlPlaneLabel: "",
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cp, controlplanev1.GroupVersion.WithKind("Ok3sControlPlane")),
+			},
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: "test",
+			Version:     pointer.String(version),
+		},
+	}
+	if failureDomain != "" {
+		m.Spec.FailureDomain = pointer.String(failureDomain)
+	}
+	if ready {
+		m.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: name}
+		conditions.MarkTrue(m, clusterv1.ReadyCondition)
+	}
+	return m
+}
+
+func newService(t *testing.T, cluster *clusterv1.Cluster, cp *controlplanev1.Ok3sControlPlane, objs ...client.Object) (*Service, client.Client) {
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(append(objs, cluster, cp)...).Build()
+	logger := logr.Discard()
+	cpScope, err := scope.NewControlPlaneScope(scope.ControlPlaneScopeParams{
+		Client:       c,
+		Logger:       &logger,
+		Cluster:      cluster,
+		ControlPlane: cp,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewService(cpScope), c
+}
+
+func listMachines(t *testing.T, c client.Client) []clusterv1.Machine {
+	machines := &clusterv1.MachineList{}
+	if err := c.List(context.Background(), machines); err != nil {
+		t.Fatal(err)
+	}
+	return machines.Items
+}
+
+func TestReconcileFirstMachine(t *testing.T) {
+	ctx := context.Background()
+	cp := newControlPlane(3)
+	s, c := newService(t, newCluster("b", "a"), cp, newInfraTemplate())
+
+	res, err := s.Reconcile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RequeueAfter != requeueAfter {
+		t.Errorf("expected a requeue after %v, got %v", requeueAfter, res)
+	}
+
+	machines := listMachines(t, c)
+	if len(machines) != 1 {
+		t.Fatalf("expected only the first machine to be created, got %d", len(machines))
+	}
+	m := machines[0]
+	if !metav1.IsControlledBy(&m, cp) {
+		t.Errorf("expected the machine to be controlled by the control plane, got %v", m.OwnerReferences)
+	}
+	for k, v := range map[string]string{
+		clusterv1.ClusterNameLabel:             "test",
+		clusterv1.MachineControlPlaneLabel:     "",
+		clusterv1.MachineControlPlaneNameLabel: "test-cp",
+		"role":                                 "server",
+	} {
+		if got, ok := m.Labels[k]; !ok || got != v {
+			t.Errorf("expected label %s=%q, got %v", k, v, m.Labels)
+		}
+	}
+	if pointer.StringDeref(m.Spec.Version, "") != version {
+		t.Errorf("expected version %s, got %v", version, m.Spec.Version)
+	}
+	if fd := pointer.StringDeref(m.Spec.FailureDomain, ""); fd != "a" && fd != "b" {
+		t.Errorf("expected a failure domain of the cluster, got %q", fd)
+	}
+
+	config := &bootstrapv1.Ok3sConfig{}
+	ref := m.Spec.Bootstrap.ConfigRef
+	if ref == nil || ref.Kind != "Ok3sConfig" {
+		t.Fatalf("expected an Ok3sConfig bootstrap ref, got %v", ref)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Spec.Version != version {
+		t.Errorf("expected the bootstrap config version %s, got %q", version, config.Spec.Version)
+	}
+
+	infra := &unstructured.Unstructured{}
+	infra.SetGroupVersionKind(infraMachineGVK)
+	if m.Spec.InfrastructureRef.Kind != infraMachineGVK.Kind {
+		t.Errorf("expected a %s infrastructure ref, got %v", infraMachineGVK.Kind, m.Spec.InfrastructureRef)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: m.Spec.InfrastructureRef.Name}, infra); err != nil {
+		t.Fatal(err)
+	}
+	if image, _, _ := unstructured.NestedString(infra.Object, "spec", "image"); image != "okr" {
+		t.Errorf("expected the infrastructure machine to be cloned from the template, got %v", infra.Object)
+	}
+
+	if !conditions.IsFalse(cp, controlplanev1.ResizedCondition) ||
+		conditions.GetReason(cp, controlplanev1.ResizedCondition) != controlplanev1.ScalingUpReason {
+		t.Errorf("expected the control plane to be scaling up, got %v", conditions.Get(cp, controlplanev1.ResizedCondition))
+	}
+}
+
+func TestReconcileWaits(t *testing.T) {
+	tests := []struct {
+		name     string
+		cluster  *clusterv1.Cluster
+		machines func(cp *controlplanev1.Ok3sControlPlane) []client.Object
+		requeue  bool
+	}{
+		{
+			name:    "infrastructure not ready",
+			cluster: &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test"}},
+			machines: func(*controlplanev1.Ok3sControlPlane) []client.Object {
+				return nil
+			},
+		},
+		{
+			name:    "not initialized",
+			cluster: newCluster(),
+			machines: func(cp *controlplanev1.Ok3sControlPlane) []client.Object {
+				return []client.Object{newMachine(cp, "m1", "", 0, false)}
+			},
+			requeue: true,
+		},
+		{
+			name:    "not all machines ready",
+			cluster: newCluster(),
+			machines: func(cp *controlplanev1.Ok3sControlPlane) []client.Object {
+				return []client.Object{newMachine(cp, "m1", "", 0, true), newMachine(cp, "m2", "", 1, false)}
+			},
+			requeue: true,
+		},
+		{
+			name:    "machine deleting",
+			cluster: newCluster(),
+			machines: func(cp *controlplanev1.Ok3sControlPlane) []client.Object {
+				m := newMachine(cp, "m2", "", 1, true)
+				m.DeletionTimestamp = &metav1.Time{Time: baseTime}
+				m.Finalizers = []string{clusterv1.MachineFinalizer}
+				return []client.Object{newMachine(cp, "m1", "", 0, true), m}
+			},
+			requeue: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := newControlPlane(3)
+			objs := tt.machines(cp)
+			s, c := newService(t, tt.cluster, cp, append(objs, newInfraTemplate())...)
+
+			res, err := s.Reconcile(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := res.RequeueAfter > 0; got != tt.requeue {
+				t.Errorf("expected requeue %v, got %v", tt.requeue, res)
+			}
+			if got := len(listMachines(t, c)); got != len(objs) {
+				t.Errorf("expected %d machines, got %d", len(objs), got)
+			}
+		})
+	}
+}
+
+func TestReconcileScaleUp(t *testing.T) {
+	cp := newControlPlane(3)
+	s, c := newService(t, newCluster("a", "b", "c"), cp,
+		newInfraTemplate(),
+		newMachine(cp, "m1", "a", 0, true),
+		newMachine(cp, "m2", "c", 1, true))
+
+	if _, err := s.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	machines := listMachines(t, c)
+	if len(machines) != 3 {
+		t.Fatalf("expected one machine to be added, got %d machines", len(machines))
+	}
+	for _, m := range machines {
+		if m.Name == "m1" || m.Name == "m2" {
+			continue
+		}
+		if fd := pointer.StringDeref(m.Spec.FailureDomain, ""); fd != "b" {
+			t.Errorf("expected the new machine in the empty failure domain b, got %q", fd)
+		}
+	}
+}
+
+func TestReconcileScaleDown(t *testing.T) {
+	cp := newControlPlane(1)
+	// m1 is the oldest machine, but m2 is the oldest of the failure domain with the most machines
+	s, c := newService(t, newCluster("a", "b"), cp,
+		newMachine(cp, "m1", "b", 0, true),
+		newMachine(cp, "m2", "a", 1, true),
+		newMachine(cp, "m3", "a", 2, true))
+
+	if _, err := s.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, m := range listMachines(t, c) {
+		names = append(names, m.Name)
+	}
+	if len(names) != 2 || names[0] != "m1" || names[1] != "m3" {
+		t.Errorf("expected m2 to be deleted, got machines %v", names)
+	}
+	if conditions.GetReason(cp, controlplanev1.ResizedCondition) != controlplanev1.ScalingDownReason {
+		t.Errorf("expected the control plane to be scaling down, got %v", conditions.Get(cp, controlplanev1.ResizedCondition))
+	}
+}
+
+func TestReconcileStatus(t *testing.T) {
+	cp := newControlPlane(3)
+	old := newMachine(cp, "m1", "", 0, true)
+	old.Spec.Version = pointer.String("v1.27.8+k3s2")
+	s, _ := newService(t, newCluster(), cp,
+		old,
+		newMachine(cp, "m2", "", 1, true),
+		newMachine(cp, "m3", "", 2, true))
+
+	res, err := s.Reconcile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RequeueAfter != 0 {
+		t.Errorf("expected no requeue, got %v", res)
+	}
+
+	status := cp.Status
+	if status.Selector != "cluster.x-k8s.io/cluster-name=test,cluster.x-k8s.io/control-plane" {
+		t.Errorf("unexpected selector %q", status.Selector)
+	}
+	if status.Replicas != 3 || status.UpdatedReplicas != 2 || status.ReadyReplicas != 3 {
+		t.Errorf("expected 3 replicas, 2 updated and 3 ready, got %d, %d and %d",
+			status.Replicas, status.UpdatedReplicas, status.ReadyReplicas)
+	}
+	if pointer.StringDeref(status.Version, "") != "v1.27.8+k3s2" {
+		t.Errorf("expected the lowest version, got %v", status.Version)
+	}
+	if !status.Initialized || !status.Ready {
+		t.Errorf("expected the control plane to be initialized and ready, got %+v", status)
+	}
+	if status.ObservedGeneration != 2 {
+		t.Errorf("expected observed generation 2, got %d", status.ObservedGeneration)
+	}
+	for _, condition := range []clusterv1.ConditionType{
+		controlplanev1.AvailableCondition,
+		controlplanev1.MachinesReadyCondition,
+		controlplanev1.ResizedCondition,
+	} {
+		if !conditions.IsTrue(cp, condition) {
+			t.Errorf("expected condition %s to be true, got %v", condition, conditions.Get(cp, condition))
+		}
+	}
+}
+
+func TestReconcileStatusNotInitialized(t *testing.T) {
+	cp := newControlPlane(1)
+	s, _ := newService(t, newCluster(), cp, newMachine(cp, "m1", "", 0, false))
+
+	if _, err := s.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if cp.Status.Initialized || cp.Status.Ready {
+		t.Errorf("expected the control plane not to be initialized or ready, got %+v", cp.Status)
+	}
+	if !conditions.IsFalse(cp, controlplanev1.AvailableCondition) ||
+		conditions.GetReason(cp, controlplanev1.AvailableCondition) != controlplanev1.WaitingForBootstrapReason {
+		t.Errorf("expected the control plane to wait for bootstrap, got %v", conditions.Get(cp, controlplanev1.AvailableCondition))
+	}
+}
+
+func TestScaleDownWaitsForReadyNodes(t *testing.T) {
+	cp := newControlPlane(1)
+	machines := []client.Object{
+		newMachine(cp, "m1", "b", 0, true),
+		newMachine(cp, "m2", "a", 1, true),
+		newMachine(cp, "m3", "a", 2, false),
+	}
+	s, c := newService(t, newCluster("a", "b"), cp, machines...)
+	owned, err := s.controlPlaneMachines(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// m2 would be deleted but the etcd member of m3 may not be healthy
+	res, err := s.scaleDown(context.Background(), owned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RequeueAfter != requeueAfter {
+		t.Errorf("expected a requeue after %v, got %v", requeueAfter, res)
+	}
+	if got := len(listMachines(t, c)); got != len(machines) {
+		t.Errorf("expected no machine to be deleted, got %d machines", got)
+	}
+}
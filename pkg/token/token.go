@@ -9,8 +9,10 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
@@ -19,7 +21,7 @@ func Lookup(ctx context.Context, ctrlclient client.Client, clusterKey client.Obj
 	var err error
 
 	if s, err = getSecret(ctx, ctrlclient, clusterKey); err != nil {
-		return nil, fmt.Errorf("failed to lookup token: %v", err)
+		return nil, fmt.Errorf("failed to lookup token: %w", err)
 	}
 	if val, ok := s.Data["value"]; ok {
 		ret := string(val)
@@ -40,12 +42,18 @@ func Reconcile(ctx context.Context, ctrlclient client.Client, clusterKey client.
 			_, err = generateAndStore(ctx, ctrlclient, clusterKey, owner)
 			return err
 		}
+		return fmt.Errorf("failed to lookup token: %w", err)
 	}
 
 	// Secret exists
 	// Ensure the secret has correct ownership; this is necessary because at one point, the secret was owned by KThreesConfig
 	if !metav1.IsControlledBy(s, owner) {
-		upsertControllerRef(s, owner)
+		// the TypeMeta of the owner is empty when it was read with a typed client
+		gvk, err := apiutil.GVKForObject(owner, ctrlclient.Scheme())
+		if err != nil {
+			return fmt.Errorf("failed to update ownership of token: %w", err)
+		}
+		upsertControllerRef(s, owner, gvk)
 		if err := ctrlclient.Update(ctx, s); err != nil {
 			return fmt.Errorf("failed to update ownership of token: %v", err)
 		}
@@ -118,8 +126,8 @@ func generateAndStore(ctx context.Context, ctrlclient client.Client, clusterKey
 // if one exists or appends the new controller ref if one does not exist, and returns the updated controllee
 // This is meant to be used in place of controllerutil.SetControllerReference(...), which would throw an error
 // if there were already an existing controller ref.
-func upsertControllerRef(controllee client.Object, controller client.Object) {
-	newControllerRef := metav1.NewControllerRef(controller, controller.GetObjectKind().GroupVersionKind())
+func upsertControllerRef(controllee client.Object, controller client.Object, gvk schema.GroupVersionKind) {
+	newControllerRef := metav1.NewControllerRef(controller, gvk)
 
 	// Iterate through existing owner references
 	var updatedOwnerReferences []metav1.OwnerReference
@@ -0,0 +1,114 @@
+package token
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	controlplanev1 "github.com/oneblock-ai/okr/api/controlplane/v1"
+)
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := controlplanev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	// a typed object read from the API server has an empty TypeMeta
+	cp := &controlplanev1.Ok3sControlPlane{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test", UID: "cp-uid"}}
+	key := client.ObjectKey{Namespace: "default", Name: "test"}
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(cp).Build()
+
+	if _, err := Lookup(ctx, c, key); err == nil {
+		t.Fatal("expected the lookup of a missing token to fail")
+	}
+
+	if err := Reconcile(ctx, c, key, cp); err != nil {
+		t.Fatal(err)
+	}
+	tokn, err := Lookup(ctx, c, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *tokn == "" {
+		t.Error("expected a generated token")
+	}
+
+	// the token is kept on the next reconcile
+	if err := Reconcile(ctx, c, key, cp); err != nil {
+		t.Fatal(err)
+	}
+	again, err := Lookup(ctx, c, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *again != *tokn {
+		t.Errorf("expected token %q to be kept, got %q", *tokn, *again)
+	}
+
+	s := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "test-token"}, s); err != nil {
+		t.Fatal(err)
+	}
+	if !metav1.IsControlledBy(s, cp) {
+		t.Errorf("expected the secret to be controlled by the control plane, got %v", s.OwnerReferences)
+	}
+	if s.Labels[clusterv1.ClusterNameLabel] != "test" {
+		t.Errorf("expected the cluster name label, got %v", s.Labels)
+	}
+}
+
+func TestReconcileOwnership(t *testing.T) {
+	ctx := context.Background()
+	cp := &controlplanev1.Ok3sControlPlane{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "test", UID: "cp-uid"}}
+	isController := true
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "test-token",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "bootstrap.cluster.x-k8s.io/v1",
+				Kind:       "Ok3sConfig",
+				Name:       "old",
+				UID:        "old-uid",
+				Controller: &isController,
+			}},
+		},
+		Data: map[string][]byte{"value": []byte("token")},
+	}
+	key := client.ObjectKey{Namespace: "default", Name: "test"}
+	c := fake.NewClientBuilder().WithScheme(newScheme(t)).WithObjects(cp, secret).Build()
+
+	if err := Reconcile(ctx, c, key, cp); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(secret), s); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.OwnerReferences) != 1 {
+		t.Fatalf("expected the controller ref to be replaced, got %v", s.OwnerReferences)
+	}
+	ref := s.OwnerReferences[0]
+	if ref.UID != cp.UID || ref.Kind != "Ok3sControlPlane" || ref.APIVersion != controlplanev1.GroupVersion.String() {
+		t.Errorf("expected the control plane to own the secret, got %v", ref)
+	}
+	if string(s.Data["value"]) != "token" {
+		t.Errorf("expected the token to be kept, got %q", s.Data["value"])
+	}
+}